@@ -0,0 +1,57 @@
+package svg2icon
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// androidIconName is the file name of the launcher icon in every density directory.
+const androidIconName = "ic_launcher.png"
+
+// androidDensities maps the mipmap resource directories of an Android project
+// to the launcher icon size of their screen density.
+var androidDensities = []struct {
+	dir  string
+	size int
+}{
+	{"mipmap-mdpi", 48},
+	{"mipmap-hdpi", 72},
+	{"mipmap-xhdpi", 96},
+	{"mipmap-xxhdpi", 144},
+	{"mipmap-xxxhdpi", 192},
+}
+
+// createAndroid writes an ic_launcher.png for every Android screen density
+// into its mipmap-<density> directory below resDir, matching the layout of
+// an Android Studio res/ directory. Missing directories are created and
+// existing files are only replaced with --force.
+func createAndroid(src source, resDir string, opts options) error {
+	if info, err := os.Stat(resDir); err == nil && !info.IsDir() {
+		return errors.New("Android output must be a directory.")
+	}
+
+	// Parse the SVG once for all densities
+	icon, err := src.parse()
+	if err != nil {
+		return err
+	}
+	for _, density := range androidDensities {
+		if err := opts.context().Err(); err != nil {
+			return err
+		}
+		dir := filepath.Join(resDir, density.dir)
+		if !opts.dryRun {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+
+		err := writePng(icon, filepath.Join(dir, androidIconName), density.size, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package svg2icon
+
+import (
+	"errors"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"path/filepath"
+)
+
+// createAppStore writes the 1024x1024 App Store marketing icon. An existing
+// directory receives <name>.png, any other output is used as the file path.
+// An existing file is only replaced with --force.
+func createAppStore(src source, output string, opts options) error {
+	switch classifyPath(output) {
+	case DirectoryPath:
+		output = filepath.Join(output, src.name()+".png")
+	case InvalidPath:
+		return errors.New("Invalid output filepath.")
+	}
+
+	if err := checkOverwrite(output, opts.force); err != nil {
+		return err
+	}
+	if opts.dryRun {
+		printPlan("png", output, []int{png.AppIconSize})
+		return nil
+	}
+
+	icon, err := src.parse()
+	if err != nil {
+		return err
+	}
+	data, err := opts.render(func() ([]byte, error) {
+		return png.RenderAppIconPngOpts(icon, opts.renderOptions())
+	})
+	if err != nil {
+		return err
+	}
+	return writeOutput(output, "png", []int{png.AppIconSize}, data, opts)
+}
@@ -0,0 +1,240 @@
+package svg2icon
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// batchResult records the outcome of converting a single file in batch mode.
+type batchResult struct {
+	input   string
+	dir     string // Directory of the input relative to the batch directory
+	err     error
+	outputs []outputInfo // Files written for the input, only collected with --json
+}
+
+// batchInput is an SVG file to convert in batch mode.
+type batchInput struct {
+	path string // Path of the SVG file
+	dir  string // Directory relative to the batch directory, "." for the top level
+}
+
+// runBatch converts every SVG matching the glob pattern, or inside the
+// directory given as pattern, into an ICO and ICNS file inside outDir, named
+// after the input file. With --recursive the subdirectories of the directory
+// are converted too, into the same subdirectories of outDir.
+//
+// Up to opts.concurrency files are converted at the same time, each by a single
+// worker from start to end. Every file is attempted even if an earlier one
+// failed. While they run, a progress bar is shown if stderr is a terminal.
+// A summary of all results is printed at the end in input order, and an error
+// is returned if any file failed.
+func runBatch(pattern string, outDir string, opts options) error {
+	inputs, err := batchInputs(pattern, opts.recursive)
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return fmt.Errorf("No files match %q.", pattern)
+	}
+	if classifyPath(outDir) != DirectoryPath {
+		return errors.New("Batch output must be an existing directory.")
+	}
+
+	results := make([]batchResult, len(inputs))
+	jobs := make(chan int)
+	progress := newProgressBar(len(inputs), opts)
+	opts.progress = progress
+
+	var wg sync.WaitGroup
+	for range min(opts.concurrency, len(inputs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				progress.start(inputs[i].path)
+				results[i] = convertInput(inputs[i].path, filepath.Join(outDir, inputs[i].dir), opts)
+				results[i].dir = inputs[i].dir
+				progress.finish()
+			}
+		}()
+	}
+	for i := range inputs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	progress.clear()
+
+	return summarizeBatch(results, opts)
+}
+
+// batchInputs returns the files matching the glob pattern, or the SVG files
+// inside the directory given as pattern. Subdirectories are only searched
+// with recursive, which requires a directory.
+func batchInputs(pattern string, recursive bool) ([]batchInput, error) {
+	if classifyPath(pattern) != DirectoryPath {
+		if recursive {
+			return nil, fmt.Errorf("--recursive needs a directory as --batch, got %q.", pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid batch pattern %q.", pattern)
+		}
+		inputs := make([]batchInput, len(matches))
+		for i, match := range matches {
+			inputs[i] = batchInput{path: match, dir: "."}
+		}
+		return inputs, nil
+	}
+
+	var inputs []batchInput
+	err := filepath.WalkDir(pattern, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != pattern && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(path), ".svg") {
+			dir, err := filepath.Rel(pattern, filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+			inputs = append(inputs, batchInput{path: path, dir: dir})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Can't read batch directory: %s.", err)
+	}
+	return inputs, nil
+}
+
+// convertInput converts a single input of the batch and records its result.
+// With --json the written files are collected per input, so they are reported
+// in input order regardless of which worker finished first.
+func convertInput(input string, outDir string, opts options) batchResult {
+	if opts.json {
+		opts.report = &outputReport{}
+	}
+	err := withTimeout(opts, func(opts options) error {
+		return convertFile(input, outDir, opts)
+	})
+	result := batchResult{input: input, err: err}
+	if opts.report != nil {
+		result.outputs = opts.report.outputs
+	}
+	return result
+}
+
+// convertFile writes the ICO and/or ICNS file, as selected by --format, for a
+// single input file into outDir, named by the --template.
+// Both formats are attempted even if the first one fails.
+func convertFile(input string, outDir string, opts options) error {
+	if err := validSvg(input); err != nil {
+		return err
+	}
+
+	src := source{path: input}
+	opts, err := prepare(&src, opts)
+	if err != nil {
+		return err
+	}
+
+	path := func(format string, sizes []int) (string, error) {
+		fileName, err := expandTemplate(opts.template, src.name(), format, slices.Max(sizes))
+		return filepath.Join(outDir, fileName), err
+	}
+	var icoPath, icnsPath string
+	if opts.format != "icns" {
+		if icoPath, err = path("ico", opts.sizes); err != nil {
+			return err
+		}
+	}
+	if opts.format != "ico" {
+		if icnsPath, err = path("icns", opts.icnsSizes()); err != nil {
+			return err
+		}
+	}
+
+	// Mirror the subdirectory of the input with --recursive
+	if !opts.dryRun {
+		if err := os.MkdirAll(outDir, 0755); err != nil {
+			if errors.Is(err, fs.ErrPermission) {
+				return fmt.Errorf("Can't create output directory %s: permission denied.", outDir)
+			}
+			return fmt.Errorf("Can't create output directory %s.", outDir)
+		}
+	}
+
+	return withBlankCheck(src, opts, func(opts options) error {
+		return createPaths(src, icoPath, icnsPath, opts)
+	})
+}
+
+// summarizeBatch prints the outcome of every file followed by a summary line.
+// With --json only the failures are printed, to stderr, followed by the JSON
+// summary of all written files on stdout.
+// Returns an error if at least one file failed.
+func summarizeBatch(results []batchResult, opts options) error {
+	failed := 0
+	report := &outputReport{}
+	for _, result := range results {
+		for _, output := range result.outputs {
+			report.add(output)
+		}
+		if result.err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "[svg2icon] FAIL %s: %s\n", result.input, result.err)
+		} else if !opts.json {
+			fmt.Printf("OK   %s\n", result.input)
+		}
+	}
+
+	if opts.json {
+		if err := report.print(); err != nil {
+			return err
+		}
+	} else {
+		if opts.recursive {
+			printDirectoryCounts(results)
+		}
+		fmt.Printf("\nConverted %d of %d files, %d failed.\n", len(results)-failed, len(results), failed)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed.", failed, len(results))
+	}
+	return nil
+}
+
+// printDirectoryCounts prints how many files of every input directory were
+// converted, in the order the directories were first converted.
+func printDirectoryCounts(results []batchResult) {
+	var dirs []string
+	total := map[string]int{}
+	converted := map[string]int{}
+	for _, result := range results {
+		if total[result.dir] == 0 {
+			dirs = append(dirs, result.dir)
+		}
+		total[result.dir]++
+		if result.err == nil {
+			converted[result.dir]++
+		}
+	}
+
+	fmt.Println()
+	for _, dir := range dirs {
+		fmt.Printf("%s: converted %d of %d files\n", dir, converted[dir], total[dir])
+	}
+}
@@ -0,0 +1,200 @@
+package svg2icon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/icns"
+	"github.com/julian-bruyers/svg2icon/internal/ico"
+	svgpng "github.com/julian-bruyers/svg2icon/internal/png"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/webp"
+	"image"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// doctorSvg is the known input of --doctor: an opaque square with a circle,
+// so every render has visible pixels in the center.
+const doctorSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">
+<rect width="64" height="64" rx="12" fill="#2b6cb0"/>
+<circle cx="32" cy="32" r="18" fill="#ffffff"/>
+</svg>
+`
+
+// doctorImageSize is the size of the single images rendered by --doctor.
+const doctorImageSize = 64
+
+// doctorCheck is a check of --doctor for one output format.
+type doctorCheck struct {
+	name string
+
+	// check converts the test SVG at svgPath into the format inside dir and
+	// reads the result back. Returns a short description of what was
+	// verified, or an error.
+	check func(svgPath string, dir string) (string, error)
+}
+
+// doctorChecks are the checks run by --doctor, one per output format.
+var doctorChecks = []doctorCheck{
+	{"png", func(svgPath string, dir string) (string, error) {
+		return checkImage(svgpng.SvgToPng, png.Decode, svgPath)
+	}},
+	{"bmp", func(svgPath string, dir string) (string, error) {
+		return checkImage(svgpng.SvgToBmp, bmp.Decode, svgPath)
+	}},
+	{"gif", func(svgPath string, dir string) (string, error) {
+		return checkImage(svgpng.SvgToGif, gif.Decode, svgPath)
+	}},
+	{"webp", func(svgPath string, dir string) (string, error) {
+		return checkImage(svgpng.SvgToWebp, webp.Decode, svgPath)
+	}},
+	{"ico", checkIco},
+	{"icns", checkIcns},
+}
+
+// runDoctor renders a built-in test SVG to every format in a temporary
+// directory, reads the outputs back and prints PASS or FAIL for each format.
+// Returns an error if a check failed.
+func runDoctor() error {
+	dir, err := os.MkdirTemp("", "svg2icon-doctor-")
+	if err != nil {
+		return fmt.Errorf("Can't create a temporary directory: %s.", err)
+	}
+	defer os.RemoveAll(dir)
+
+	svgPath := filepath.Join(dir, "doctor.svg")
+	if err := os.WriteFile(svgPath, []byte(doctorSvg), 0644); err != nil {
+		return fmt.Errorf("Can't write the test SVG: %s.", err)
+	}
+
+	failed := 0
+	for _, check := range doctorChecks {
+		detail, err := check.check(svgPath, dir)
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %-5s %s\n", check.name, err)
+			continue
+		}
+		fmt.Printf("PASS %-5s %s\n", check.name, detail)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed.", failed, len(doctorChecks))
+	}
+	fmt.Printf("\nAll %d checks passed.\n", len(doctorChecks))
+	return nil
+}
+
+// checkImage renders the SVG with convert and decodes the result, which must
+// be a doctorImageSize square with visible pixels in the center.
+func checkImage(convert func(string, int) ([]byte, error), decode func(io.Reader) (image.Image, error), svgPath string) (string, error) {
+	data, err := convert(svgPath, doctorImageSize)
+	if err != nil {
+		return "", err
+	}
+	img, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("output doesn't decode: %w", err)
+	}
+	if err := checkPixels(img, doctorImageSize); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%dx%d image, %d bytes", doctorImageSize, doctorImageSize, len(data)), nil
+}
+
+// checkPixels checks that img is a size x size square whose center pixel is
+// visible.
+func checkPixels(img image.Image, size int) error {
+	bounds := img.Bounds()
+	if bounds.Dx() != size || bounds.Dy() != size {
+		return fmt.Errorf("expected %dx%d, got %dx%d", size, size, bounds.Dx(), bounds.Dy())
+	}
+	_, _, _, alpha := img.At(bounds.Min.X+size/2, bounds.Min.Y+size/2).RGBA()
+	if alpha == 0 {
+		return errors.New("render is transparent")
+	}
+	return nil
+}
+
+// checkIco writes an ICO file with the default sizes and reads its directory
+// and every image back.
+func checkIco(svgPath string, dir string) (string, error) {
+	path := filepath.Join(dir, "doctor.ico")
+	if err := ico.CreateIcoSizes(svgPath, path, ico.IconSizes); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := ico.ReadIco(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	if len(entries) != len(ico.IconSizes) {
+		return "", fmt.Errorf("expected %d entries, got %d", len(ico.IconSizes), len(entries))
+	}
+	for i, entry := range entries {
+		size, _ := entry.Size()
+		if size != ico.IconSizes[i] {
+			return "", fmt.Errorf("entry %d is %dpx, expected %dpx", i, size, ico.IconSizes[i])
+		}
+		end := uint64(entry.ImageOffset) + uint64(entry.BytesInRes)
+		if end > uint64(len(data)) {
+			return "", fmt.Errorf("entry %d (%dpx) extends past the end of the file", i, size)
+		}
+		img, err := png.Decode(bytes.NewReader(data[entry.ImageOffset:end]))
+		if err != nil {
+			return "", fmt.Errorf("entry %d (%dpx) doesn't decode: %w", i, size, err)
+		}
+		if err := checkPixels(img, size); err != nil {
+			return "", fmt.Errorf("entry %d: %w", i, err)
+		}
+	}
+	return fmt.Sprintf("%d entries, %d bytes", len(entries), len(data)), nil
+}
+
+// checkIcns writes an ICNS file with the standard types and reads every
+// entry back.
+func checkIcns(svgPath string, dir string) (string, error) {
+	path := filepath.Join(dir, "doctor.icns")
+	if err := icns.CreateIcns(svgPath, path); err != nil {
+		return "", err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	entries, err := icns.ReadIcns(file)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) != len(icns.StandardIconTypes) {
+		return "", fmt.Errorf("expected %d entries, got %d", len(icns.StandardIconTypes), len(entries))
+	}
+	for _, entry := range entries {
+		osType := string(entry.OSType[:])
+		i := slices.IndexFunc(icns.StandardIconTypes, func(iconType icns.IconType) bool {
+			return iconType.OSType == osType
+		})
+		if i < 0 {
+			return "", fmt.Errorf("unexpected entry %s", osType)
+		}
+		img, err := png.Decode(bytes.NewReader(entry.Data))
+		if err != nil {
+			return "", fmt.Errorf("entry %s doesn't decode: %w", osType, err)
+		}
+		if err := checkPixels(img, icns.StandardIconTypes[i].Size); err != nil {
+			return "", fmt.Errorf("entry %s: %w", osType, err)
+		}
+	}
+	return fmt.Sprintf("%d entries", len(entries)), nil
+}
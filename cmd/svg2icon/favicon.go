@@ -0,0 +1,108 @@
+package svg2icon
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"github.com/srwiley/oksvg"
+	"path/filepath"
+)
+
+// faviconIcoSizes are the sizes embedded in favicon.ico.
+var faviconIcoSizes = []int{16, 32, 48}
+
+// faviconPngs maps the PNG files of the favicon bundle to their pixel size.
+var faviconPngs = []struct {
+	name string
+	size int
+}{
+	{"favicon-16x16.png", 16},
+	{"favicon-32x32.png", 32},
+	{"apple-touch-icon.png", 180},
+}
+
+// faviconWebpName and faviconWebpSize describe the optional WebP favicon.
+const (
+	faviconWebpName = "favicon.webp"
+	faviconWebpSize = 32
+)
+
+// faviconWebpHtml references the optional WebP favicon.
+const faviconWebpHtml = `<link rel="icon" type="image/webp" sizes="32x32" href="/favicon.webp">
+`
+
+// faviconHtml references every file of the favicon bundle. The ICO lists
+// every size it holds, see faviconIcoSizes.
+const faviconHtml = `<link rel="icon" href="/favicon.ico" sizes="16x16 32x32 48x48">
+<link rel="icon" type="image/png" sizes="32x32" href="/favicon-32x32.png">
+<link rel="icon" type="image/png" sizes="16x16" href="/favicon-16x16.png">
+<link rel="apple-touch-icon" sizes="180x180" href="/apple-touch-icon.png">
+`
+
+// createFavicons writes a complete web favicon bundle into outDir:
+// favicon.ico (16/32/48), favicon-16x16.png, favicon-32x32.png,
+// apple-touch-icon.png (180x180) and a favicons.html snippet with the
+// matching <link> tags. With --webp a 32x32 favicon.webp is added as well.
+// Existing files are only replaced with --force.
+func createFavicons(src source, outDir string, opts options) error {
+	if classifyPath(outDir) != DirectoryPath {
+		return errors.New("Favicon output must be an existing directory.")
+	}
+
+	icoOpts := opts
+	icoOpts.sizes = faviconIcoSizes
+	err := src.createIco(filepath.Join(outDir, "favicon.ico"), icoOpts)
+	if err != nil {
+		return err
+	}
+
+	// Parse the SVG once for all PNG variants
+	icon, err := src.parse()
+	if err != nil {
+		return err
+	}
+	for _, variant := range faviconPngs {
+		err := writePng(icon, filepath.Join(outDir, variant.name), variant.size, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	html := faviconHtml
+	if opts.webp {
+		if err := writeWebp(icon, filepath.Join(outDir, faviconWebpName), faviconWebpSize, opts); err != nil {
+			return err
+		}
+		html = faviconWebpHtml + html
+	}
+
+	path := filepath.Join(outDir, "favicons.html")
+	if err := checkOverwrite(path, opts.force); err != nil {
+		return err
+	}
+	if opts.dryRun {
+		fmt.Printf("%-5s %s\n", "html", path)
+		return nil
+	}
+	return writeOutput(path, "html", nil, []byte(html), opts)
+}
+
+// writeWebp renders the parsed icon at size pixels and writes it to path as WebP.
+// An existing file is only replaced when --force is set.
+func writeWebp(icon *oksvg.SvgIcon, path string, size int, opts options) error {
+	if err := checkOverwrite(path, opts.force); err != nil {
+		return err
+	}
+	if opts.dryRun {
+		printPlan("webp", path, []int{size})
+		return nil
+	}
+
+	webpData, err := opts.render(func() ([]byte, error) {
+		return png.RenderIconWebpOpts(icon, size, opts.renderOptions())
+	})
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, "webp", []int{size}, webpData, opts)
+}
@@ -0,0 +1,79 @@
+package svg2icon
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/ico"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// faviconLink, linkSizes and linkHref match a <link> tag of favicons.html
+// and its sizes and href attributes.
+var (
+	faviconLink = regexp.MustCompile(`<link [^>]*>`)
+	linkSizes   = regexp.MustCompile(`sizes="([^"]*)"`)
+	linkHref    = regexp.MustCompile(`href="/([^"]*)"`)
+)
+
+func TestFaviconHtmlSizes(t *testing.T) {
+	dir := t.TempDir()
+	svgPath := filepath.Join(dir, "logo.svg")
+	if err := os.WriteFile(svgPath, []byte(testSvg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(dir, "favicons")
+	if err := os.Mkdir(outDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := createFavicons(source{path: svgPath}, outDir, options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	html, err := os.ReadFile(filepath.Join(outDir, "favicons.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := faviconLink.FindAllString(string(html), -1)
+	if len(links) != 4 {
+		t.Fatalf("found %d <link> tags, want 4:\n%s", len(links), html)
+	}
+
+	// Every link lists exactly the sizes of the file it references
+	for _, link := range links {
+		sizes, href := linkSizes.FindStringSubmatch(link), linkHref.FindStringSubmatch(link)
+		if sizes == nil || href == nil {
+			t.Fatalf("%s lacks sizes or href", link)
+		}
+		name := href[1]
+		data, err := os.ReadFile(filepath.Join(outDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var want []string
+		if strings.HasSuffix(name, ".ico") {
+			entries, err := ico.ReadIco(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				width, height := entry.Size()
+				want = append(want, fmt.Sprintf("%dx%d", width, height))
+			}
+		} else {
+			config, err := png.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, fmt.Sprintf("%dx%d", config.Width, config.Height))
+		}
+		if sizes[1] != strings.Join(want, " ") {
+			t.Errorf("%s is linked with sizes %q, holds %q", name, sizes[1], strings.Join(want, " "))
+		}
+	}
+}
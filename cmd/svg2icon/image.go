@@ -0,0 +1,67 @@
+package svg2icon
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"github.com/srwiley/oksvg"
+	"path/filepath"
+)
+
+// defaultImageSize is the pixel size of single images when --sizes isn't given.
+const defaultImageSize = 256
+
+// imageFormats maps the single-image formats selectable with --format to
+// their renderer.
+var imageFormats = map[string]func(*oksvg.SvgIcon, int, png.RenderOptions) ([]byte, error){
+	"png": png.RenderIconOpts,
+	"bmp": png.RenderIconBmpOpts,
+	"gif": png.RenderIconGifOpts,
+}
+
+// createImageOutput writes a single image in the format selected with
+// --format. An existing directory receives <name>.<format>, any other
+// output is used as the file path.
+func createImageOutput(src source, output string, opts options) error {
+	switch classifyPath(output) {
+	case DirectoryPath:
+		output = filepath.Join(output, src.name()+"."+opts.format)
+	case InvalidPath:
+		return errors.New("Invalid output filepath.")
+	}
+	return src.createImage(output, opts)
+}
+
+// encodeImage returns the single image in the format selected with --format.
+func (s source) encodeImage(opts options) ([]byte, error) {
+	icon, err := s.parse()
+	if err != nil {
+		return nil, err
+	}
+	return opts.render(func() ([]byte, error) {
+		return imageFormats[opts.format](icon, opts.imageSize, opts.renderOptions())
+	})
+}
+
+// createImage writes the single image in the format selected with --format to
+// outputPath. An existing file is only replaced when --force is set.
+func (s source) createImage(outputPath string, opts options) error {
+	if err := checkOverwrite(outputPath, opts.force); err != nil {
+		return err
+	}
+	if opts.dryRun {
+		printPlan(opts.format, outputPath, []int{opts.imageSize})
+		return nil
+	}
+
+	data, err := s.encodeImage(opts)
+	if err != nil {
+		return err
+	}
+	return writeOutput(outputPath, opts.format, []int{opts.imageSize}, data, opts)
+}
+
+// imageDataURI returns an encoded single image as a base64 data: URI.
+func imageDataURI(format string, data []byte) string {
+	return "data:image/" + format + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
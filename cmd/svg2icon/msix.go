@@ -0,0 +1,66 @@
+package svg2icon
+
+import (
+	"errors"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"path/filepath"
+)
+
+// msixLogos maps the square logo files of an MSIX package to their pixel size.
+// The comments name the Package.appxmanifest attribute each file is used for.
+var msixLogos = []struct {
+	name string
+	size int
+}{
+	{"Square44x44Logo.png", 44},    // uap:VisualElements Square44x44Logo (taskbar, app list)
+	{"Square150x150Logo.png", 150}, // uap:VisualElements Square150x150Logo (medium tile)
+	{"StoreLogo.png", 50},          // Properties/Logo (Store listing and installer)
+}
+
+// The wide tile used for uap:DefaultTile Wide310x150Logo
+const (
+	msixWideName   = "Wide310x150Logo.png"
+	msixWideWidth  = 310
+	msixWideHeight = 150
+)
+
+// createMsix writes the tile and Store logos of a Windows MSIX package into
+// outDir using Microsoft's file naming. The wide tile fits the artwork into
+// the 310x150 canvas with its aspect ratio kept, centered on a transparent
+// background.
+// Existing files are only replaced with --force.
+func createMsix(src source, outDir string, opts options) error {
+	if classifyPath(outDir) != DirectoryPath {
+		return errors.New("MSIX output must be an existing directory.")
+	}
+
+	// Parse the SVG once for all logos
+	icon, err := src.parse()
+	if err != nil {
+		return err
+	}
+	for _, logo := range msixLogos {
+		err := writePng(icon, filepath.Join(outDir, logo.name), logo.size, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	path := filepath.Join(outDir, msixWideName)
+	if err := checkOverwrite(path, opts.force); err != nil {
+		return err
+	}
+	if opts.dryRun {
+		printPlan("png", path, []int{msixWideWidth, msixWideHeight})
+		return nil
+	}
+	renderOpts := opts.renderOptions()
+	renderOpts.PreserveAspectRatio = true
+	pngData, err := opts.render(func() ([]byte, error) {
+		return png.RenderIconRect(icon, msixWideWidth, msixWideHeight, renderOpts)
+	})
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, "png", []int{msixWideWidth, msixWideHeight}, pngData, opts)
+}
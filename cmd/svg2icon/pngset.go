@@ -0,0 +1,29 @@
+package svg2icon
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// createPngSet writes one <name>-<size>.png per size given with --sizes into
+// outDir, without any icon container. Existing files are only replaced with
+// --force.
+func createPngSet(src source, outDir string, opts options) error {
+	if classifyPath(outDir) != DirectoryPath {
+		return errors.New("PNG output must be an existing directory.")
+	}
+
+	// Parse the SVG once for all sizes
+	icon, err := src.parse()
+	if err != nil {
+		return err
+	}
+	for _, size := range opts.sizes {
+		path := filepath.Join(outDir, fmt.Sprintf("%s-%d.png", src.name(), size))
+		if err := writePng(icon, path, size, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package svg2icon
+
+import (
+	"fmt"
+	"golang.org/x/term"
+	"os"
+	"strings"
+	"sync"
+)
+
+// progressBarWidth is the number of cells of the bar drawn by progressBar.
+const progressBarWidth = 30
+
+// progressBar shows the progress of a batch on the terminal attached to
+// stderr: a bar, the number of finished files and the file started last.
+// It is redrawn in place on a single line and removed once the batch is done.
+//
+// The methods are safe for concurrent use by the batch workers. Except for
+// printf they do nothing on a nil *progressBar.
+type progressBar struct {
+	mu      sync.Mutex
+	out     *os.File
+	total   int
+	done    int
+	current string // File started last
+}
+
+// newProgressBar returns a progress bar for a batch of total files, or nil
+// if stderr isn't a terminal, so piped and redirected output stays clean.
+// The bar is also left out when --verbose or --dry-run print their own lines
+// while the files are converted, which would break up the bar.
+func newProgressBar(total int, opts options) *progressBar {
+	if opts.verbose || opts.dryRun || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+	return &progressBar{out: os.Stderr, total: total}
+}
+
+// start shows name as the file currently being converted.
+func (p *progressBar) start(name string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current = name
+	p.draw()
+}
+
+// finish counts one more file of the batch as done.
+func (p *progressBar) finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.draw()
+}
+
+// printf prints a message to stderr without breaking up the bar: the bar is
+// removed, the message printed and the bar drawn again below it. On a nil
+// *progressBar the message is printed directly.
+func (p *progressBar) printf(format string, args ...any) {
+	if p == nil {
+		fmt.Fprintf(os.Stderr, format, args...)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.out, "\r\033[K"+format, args...)
+	p.draw()
+}
+
+// clear removes the bar from the terminal.
+func (p *progressBar) clear() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprint(p.out, "\r\033[K")
+}
+
+// draw redraws the bar in place, cut to the width of the terminal so it
+// never wraps onto a second line. Must be called with mu held.
+func (p *progressBar) draw() {
+	filled := progressBarWidth * p.done / p.total
+	line := fmt.Sprintf("[%s%s] %d/%d %s",
+		strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled),
+		p.done, p.total, p.current)
+
+	if width, _, err := term.GetSize(int(p.out.Fd())); err == nil && width > 1 {
+		if runes := []rune(line); len(runes) >= width {
+			line = string(runes[:width-1])
+		}
+	}
+	fmt.Fprintf(p.out, "\r\033[K%s", line)
+}
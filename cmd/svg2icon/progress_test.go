@@ -0,0 +1,37 @@
+package svg2icon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProgressBarPrintf(t *testing.T) {
+	out, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	progress := &progressBar{out: out, total: 2}
+	opts := options{progress: progress}
+	progress.start("a.svg")
+	opts.warnf("Warning: %s: unsupported %s.", "a.svg", "<text>")
+	progress.finish()
+
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The warning replaces the bar on its line and the bar is drawn again below
+	bar := "[" + strings.Repeat(" ", progressBarWidth) + "] 0/2 a.svg"
+	half := "[" + strings.Repeat("=", progressBarWidth/2) + strings.Repeat(" ", progressBarWidth/2) + "] 1/2 a.svg"
+	want := "\r\033[K" + bar +
+		"\r\033[K[svg2icon] Warning: a.svg: unsupported <text>.\n" +
+		"\r\033[K" + bar +
+		"\r\033[K" + half
+	if got := string(data); got != want {
+		t.Errorf("stderr holds %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,133 @@
+package svg2icon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/atomicfile"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// outputInfo describes a written file in the --json summary.
+type outputInfo struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
+	Sizes  []int  `json:"sizes"`
+	Bytes  int64  `json:"bytes"`
+	Sha256 string `json:"sha256,omitempty"` // Hex SHA-256 of the file, only with --checksum
+}
+
+// outputReport collects the files written during a run for --json.
+// It is safe for concurrent use.
+type outputReport struct {
+	mu      sync.Mutex
+	outputs []outputInfo
+}
+
+// add records a written file.
+func (r *outputReport) add(info outputInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outputs = append(r.outputs, info)
+}
+
+// print writes the collected files to stdout as a JSON array.
+func (r *outputReport) print() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	outputs := r.outputs
+	if outputs == nil {
+		outputs = []outputInfo{}
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(outputs)
+}
+
+// writeOutput writes data to path and records it for --json as a file of
+// the given format and sizes. Nothing is written once --timeout expired.
+func writeOutput(path string, format string, sizes []int, data []byte, opts options) error {
+	if err := opts.context().Err(); err != nil {
+		return err
+	}
+	err := atomicfile.Write(path, 0644, !opts.atomicWrite, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return outputError(path, err)
+	}
+	opts.record(path, format, sizes, int64(len(data)), opts.sha256Sum(data))
+	return nil
+}
+
+// outputError explains a failure to write the output file at path, with a
+// hint for the common case of an output directory that isn't writable.
+// Errors about other files, such as the input, are returned unchanged.
+func outputError(path string, err error) error {
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != path {
+		return err
+	}
+	if errors.Is(err, fs.ErrPermission) {
+		return fmt.Errorf("Can't write %s: permission denied, check that the file and its directory are writable.", path)
+	}
+	return fmt.Errorf("Can't write %s: %s.", path, pathErr.Err)
+}
+
+// recordFile records a file that was written to path by other means for
+// --json and --checksum, reading its size or contents from disk.
+func recordFile(path string, format string, sizes []int, opts options) error {
+	if opts.checksum {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		opts.record(path, format, sizes, int64(len(data)), opts.sha256Sum(data))
+		return nil
+	}
+	if opts.report == nil {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	opts.record(path, format, sizes, info.Size(), "")
+	return nil
+}
+
+// record adds a written file to the --json report. Without --json the
+// --checksum line of the file is printed instead, in sha256sum format.
+// It does nothing without either flag.
+func (o options) record(path string, format string, sizes []int, bytes int64, sum string) {
+	if o.report == nil {
+		if sum != "" {
+			fmt.Printf("%s  %s\n", sum, path)
+		}
+		return
+	}
+	o.report.add(outputInfo{
+		Path:   path,
+		Format: format,
+		Sizes:  append([]int{}, sizes...),
+		Bytes:  bytes,
+		Sha256: sum,
+	})
+}
+
+// sha256Sum returns the hex-encoded SHA-256 of data with --checksum, or an
+// empty string without it.
+func (o options) sha256Sum(data []byte) string {
+	if !o.checksum {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
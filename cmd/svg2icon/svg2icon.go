@@ -5,14 +5,26 @@
 package svg2icon
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"github.com/julian-bruyers/svg2icon/internal/icns"
 	"github.com/julian-bruyers/svg2icon/internal/ico"
+	"github.com/julian-bruyers/svg2icon/internal/outputs"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"github.com/srwiley/oksvg"
 	"io"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type PathType int
@@ -23,6 +35,206 @@ const (
 	FilePath
 )
 
+// exitUsage is the exit code for missing, unknown or invalid arguments.
+// Failed conversions exit with 1.
+const exitUsage = 2
+
+// options holds the optional command-line flags accepted by Run.
+type options struct {
+	sizes       []int  // ICO sizes to embed
+	format      string // Format written to stdout, or single-image format ("png", "bmp" or "gif")
+	imageSize   int    // Pixel size of single-image formats, 0 if --sizes lists several sizes
+	batch       string // Glob pattern of SVG files to convert in batch mode
+	favicon     bool   // Generate a web favicon bundle
+	webp        bool   // Add a WebP favicon to the bundle
+	android     bool   // Generate Android launcher icons
+	msix        bool   // Generate Windows MSIX tile and Store logos
+	appStore    bool   // Generate the 1024x1024 App Store marketing icon
+	pngSet      bool   // Write one PNG per size instead of icons
+	template    string // File name template of batch outputs
+	recursive   bool   // Convert the subdirectories of the batch directory too
+	strict      bool   // Treat warnings about the input as errors
+	force       bool   // Overwrite existing output files
+	atomicWrite bool   // Write outputs to a temporary file renamed into place
+	dryRun      bool   // Print the planned outputs instead of writing them
+	dataURI     bool   // Write stdout output as a base64 data: URI
+	verbose     bool   // Log every render with its duration and canvas memory to stderr
+	concurrency int    // Number of files converted at the same time in batch mode
+	version     bool   // Print the version and exit
+	doctor      bool   // Run the self-test and exit
+	watch       bool   // Regenerate the outputs whenever the input changes
+	icoPath     string // Explicit ICO output path, replaces the output argument
+	icnsPath    string // Explicit ICNS output path, replaces the output argument
+	json        bool   // Print a JSON summary of the written files to stdout
+	checksum    bool   // Print the SHA-256 of every written file
+	cache       bool   // Reuse rendered images from the on-disk cache
+	noCache     bool   // Disable the on-disk cache, overrides --cache
+	layer       string // Id of the top-level group to render, empty for the whole SVG
+	cacheDir    string // Cache directory selected by --cache, empty when caching is off
+	natural     bool   // Skip sizes above the natural size declared by the SVG
+	allowBlank  bool   // Keep fully transparent renders, set after warning about them
+
+	timeout time.Duration   // Abort a conversion taking longer than this, 0 for no limit
+	ctx     context.Context // Expires after timeout, nil without --timeout
+
+	icnsTypes []icns.IconType // ICNS entries to write, nil for all standard types
+
+	report   *outputReport // Collects the written files with --json, nil otherwise
+	progress *progressBar  // Batch progress bar warnings are printed through, nil otherwise
+}
+
+// explicitPaths reports whether output paths were given with --ico or --icns.
+func (o options) explicitPaths() bool {
+	return o.icoPath != "" || o.icnsPath != ""
+}
+
+// icoOptions returns the ICO generation options selected by the flags.
+func (o options) icoOptions() ico.Options {
+	return ico.Options{Logger: o.logger(), CacheDir: o.cacheDir, SkipFeatureCheck: true, RejectBlank: !o.allowBlank, DirectWrite: !o.atomicWrite}
+}
+
+// icnsOptions returns the ICNS generation options selected by the flags.
+func (o options) icnsOptions() icns.Options {
+	return icns.Options{Logger: o.logger(), CacheDir: o.cacheDir, Types: o.icnsTypes, SkipFeatureCheck: true, RejectBlank: !o.allowBlank, DirectWrite: !o.atomicWrite}
+}
+
+// icnsTypesOrStandard returns the ICNS entries to write, the standard types
+// unless sizes were dropped from them.
+func (o options) icnsTypesOrStandard() []icns.IconType {
+	if o.icnsTypes == nil {
+		return icns.StandardIconTypes
+	}
+	return o.icnsTypes
+}
+
+// icnsSizes returns the pixel sizes of the ICNS entries in file order.
+func (o options) icnsSizes() []int {
+	var sizes []int
+	for _, iconType := range o.icnsTypesOrStandard() {
+		sizes = append(sizes, iconType.Size)
+	}
+	return sizes
+}
+
+// renderOptions returns the options for the individual images rendered by
+// the CLI.
+func (o options) renderOptions() png.RenderOptions {
+	renderOpts := png.DefaultRenderOptions()
+	renderOpts.Logger = o.logger()
+	renderOpts.RejectBlank = !o.allowBlank
+	return renderOpts
+}
+
+// warnf prints a warning line about the conversion to stderr, through the
+// batch progress bar if one is shown.
+func (o options) warnf(format string, args ...any) {
+	o.progress.printf("[svg2icon] "+format+"\n", args...)
+}
+
+// logger returns the render logger selected by --verbose, or nil.
+func (o options) logger() png.Logger {
+	if !o.verbose {
+		return nil
+	}
+	return png.LoggerFunc(func(format string, args ...any) {
+		fmt.Fprintf(os.Stderr, "[svg2icon] "+format+"\n", args...)
+	})
+}
+
+// source is the SVG input of a conversion. It either names an SVG file or
+// holds SVG markup read from stdin.
+type source struct {
+	path    string
+	isStdin bool
+	data    []byte // SVG markup read from stdin or reduced to the --layer group
+}
+
+// inMemory reports whether the markup is held in data instead of read from path.
+func (s source) inMemory() bool {
+	return s.isStdin || s.data != nil
+}
+
+// selectLayer reduces the markup of the source to the top-level group with
+// the given id. An empty layer keeps the whole SVG.
+func (s *source) selectLayer(layer string) error {
+	if layer == "" {
+		return nil
+	}
+
+	data, err := s.markup()
+	if err != nil {
+		return err
+	}
+
+	selected, err := png.SelectLayer(data, layer)
+	if err != nil {
+		return fmt.Errorf("%s: %s.", s.displayName(), err)
+	}
+	s.data = selected
+	return nil
+}
+
+// markup returns the SVG markup of the source.
+func (s source) markup() ([]byte, error) {
+	if s.inMemory() {
+		return s.data, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, errors.New("Can't read from inputfile.")
+	}
+	return data, nil
+}
+
+// limitToNaturalSize returns opts with the ICO sizes and ICNS types above the
+// natural size of the source removed when --respect-natural-size is set, and
+// prints the skipped sizes. The smallest size of each format is always kept.
+// Without a declared natural size all sizes are kept with a warning.
+func limitToNaturalSize(src source, opts options) (options, error) {
+	if !opts.natural {
+		return opts, nil
+	}
+
+	data, err := src.markup()
+	if err != nil {
+		return opts, err
+	}
+	natural, ok := png.NaturalSize(data)
+	if !ok {
+		opts.warnf("Warning: %s declares no width and height in pixels, keeping all sizes.", src.displayName())
+		return opts, nil
+	}
+
+	skipped := map[int]bool{}
+	var sizes []int
+	for i, size := range opts.sizes {
+		if size <= natural || i == 0 {
+			sizes = append(sizes, size)
+		} else {
+			skipped[size] = true
+		}
+	}
+	var types []icns.IconType
+	for i, iconType := range opts.icnsTypesOrStandard() {
+		if iconType.Size <= natural || i == 0 {
+			types = append(types, iconType)
+		} else {
+			skipped[iconType.Size] = true
+		}
+	}
+	opts.sizes = sizes
+	opts.icnsTypes = types
+
+	if len(skipped) > 0 {
+		var list []string
+		for _, size := range ico.NormalizeSizes(slices.Collect(maps.Keys(skipped))) {
+			list = append(list, strconv.Itoa(size))
+		}
+		opts.warnf("%s: skipping sizes above its natural size of %dpx: %s.", src.displayName(), natural, strings.Join(list, ", "))
+	}
+	return opts, nil
+}
+
 // Run executes the svg2icon command-line tool.
 //
 // It processes command-line arguments, validates input SVG files,
@@ -31,96 +243,908 @@ const (
 //   - Directory output: generates both ICO and ICNS files
 //   - Specific format: generates only the requested format (.ico or .icns)
 //   - Generic format: generates both formats with custom naming (.icon or no extension)
+//
+// The optional --sizes=16,32,48 flag replaces the default ICO sizes.
+// An input of "-" reads the SVG from stdin, an output of "-" writes the
+// format selected with --format to stdout.
+//
+// -h, --help, help and ? print the usage to stdout and exit with 0.
+// Missing, unknown or invalid arguments print the usage to stderr and exit
+// with 2, a failed conversion exits with 1.
 func Run() {
 	// Validate svg2icon call arguments
 	if len(os.Args) == 2 {
 		switch os.Args[1] {
-		case "help", "-h", "--help", "?":
-			showUsage()
-			os.Exit(1)
+		case "help", "?":
+			showUsage(os.Stdout)
+			return
 		}
-	} else if len(os.Args) != 3 {
-		showUsage()
-		os.Exit(1)
 	}
 
-	// Validate input path (svg)
-	input := os.Args[1]
-	err := validSvg(input)
+	opts, args, err := parseArgs(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		showUsage(os.Stdout)
+		return
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[svg2icon] %s\n", err)
-		os.Exit(1)
+		usageError(err)
 	}
 
-	// Validate output path
-	output := os.Args[2]
-	pathType := classifyPath(output)
-	if pathType == InvalidPath {
-		fmt.Fprint(os.Stderr, "[svg2icon] Invalid output filepath.\n")
-		os.Exit(1)
+	if opts.version {
+		printVersion()
+		return
 	}
 
-	// Generate both icons in given output directory
-	if pathType == DirectoryPath {
-		if output == "." {
-			output = ""
-		} else {
-			output += "/"
+	// Check the whole pipeline with a built-in SVG
+	if opts.doctor {
+		if err := runDoctor(); err != nil {
+			printError(err)
+			os.Exit(1)
 		}
-		output += strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+		return
+	}
+
+	// Reuse ICO and ICNS renders from the per-user cache
+	if opts.cache && !opts.noCache {
+		opts.cacheDir, err = png.DefaultCacheDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[svg2icon] Warning: caching disabled: %s.\n", err)
+		}
+	}
 
-		err := ico.CreateIco(input, output+".ico")
+	// Convert every file matching the batch pattern into the output directory
+	if opts.batch != "" {
+		if len(args) != 1 {
+			usageError(fmt.Errorf("--batch expects exactly one output directory, got %d arguments.", len(args)))
+		}
+		err := runBatch(opts.batch, args[0], opts)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "[svg2icon] %s\n", err)
+			os.Exit(1)
 		}
+		return
+	}
 
-		err = icns.CreateIcns(input, output+".icns")
+	// --ico and --icns replace the output argument
+	var output string
+	switch {
+	case opts.explicitPaths():
+		if len(args) != 1 {
+			usageError(fmt.Errorf("Expected only an input with --ico or --icns, got %d arguments.", len(args)))
+		}
+	case len(args) != 2:
+		usageError(fmt.Errorf("Expected an input and an output, or --ico/--icns, got %d arguments.", len(args)))
+	default:
+		output = args[1]
+	}
+	if opts.json && output == "-" {
+		usageError(errors.New("--json can't be used when writing to stdout."))
+	}
+	if opts.checksum && output == "-" {
+		usageError(errors.New("--checksum can't be used when writing to stdout."))
+	}
+	if opts.watch && (args[0] == "-" || output == "-") {
+		usageError(errors.New("--watch needs an input file and an output path, not \"-\"."))
+	}
+
+	// Validate input path (svg)
+	input := args[0]
+	src := source{path: input}
+	if input == "-" {
+		src.isStdin = true
+		src.data, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprint(os.Stderr, "[svg2icon] Can't read from stdin.\n")
+			os.Exit(1)
+		}
+	} else {
+		err = validSvg(input)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "[svg2icon] %s\n", err)
+			os.Exit(1)
 		}
 	}
 
-	// Generate icon(s) for given output path
-	if pathType == FilePath {
-		switch filepath.Ext(output) {
-		case ".ico": // Only .ico
-			err := ico.CreateIco(input, output)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "[svg2icon] %s\n", err)
-			}
-		case ".icns": // Only .icns
-			err := icns.CreateIcns(input, output)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "[svg2icon] %s\n", err)
-			}
-		case ".icon": // Both icons with custom name
-			err := ico.CreateIco(input, strings.TrimSuffix(output, filepath.Ext(output))+".ico")
+	// Write a single icon to stdout
+	if opts.dataURI && output != "-" {
+		usageError(errors.New("--data-uri requires \"-\" as output."))
+	}
+	if output == "-" {
+		err := withTimeout(opts, func(opts options) error {
+			opts, err := prepare(&src, opts)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "[svg2icon] %s\n", err)
+				return err
 			}
+			return withBlankCheck(src, opts, func(opts options) error {
+				return writeStdout(src, opts)
+			})
+		})
+		if err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		return
+	}
 
-			err = icns.CreateIcns(input, strings.TrimSuffix(output, filepath.Ext(output))+".icns")
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "[svg2icon] %s\n", err)
-			}
+	if opts.watch {
+		err := runWatch(src, output, opts)
+		if err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if opts.json {
+		opts.report = &outputReport{}
+	}
+	// Select the --layer group and check the SVG before converting it, both
+	// count towards --timeout
+	err = withTimeout(opts, func(opts options) error {
+		opts, err := prepare(&src, opts)
+		if err != nil {
+			return err
+		}
+		return withBlankCheck(src, opts, func(opts options) error {
+			return convertOutput(src, output, opts)
+		})
+	})
+	if opts.report != nil {
+		if err := opts.report.print(); err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+	}
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+}
+
+// convertOutput writes the outputs selected by opts and the <output> argument
+// for an SVG source. Every output is attempted even if an earlier one failed.
+// Returns the joined errors of all failed outputs.
+func convertOutput(src source, output string, opts options) error {
+	// Write each format to the path given with --ico and --icns
+	if opts.explicitPaths() {
+		return createExplicit(src, opts)
+	}
+
+	// Write a single image in the selected format
+	if imageFormats[opts.format] != nil {
+		return createImageOutput(src, output, opts)
+	}
+
+	// Generate the web favicon bundle into the output directory
+	if opts.favicon {
+		return createFavicons(src, output, opts)
+	}
+
+	// Generate the Android launcher icons into the res directory
+	if opts.android {
+		return createAndroid(src, output, opts)
+	}
+
+	// Generate the Windows MSIX logos into the output directory
+	if opts.msix {
+		return createMsix(src, output, opts)
+	}
+
+	// Generate the App Store marketing icon
+	if opts.appStore {
+		return createAppStore(src, output, opts)
+	}
+
+	// Write a size-suffixed PNG per size into the output directory
+	if opts.pngSet {
+		return createPngSet(src, output, opts)
+	}
+
+	// Validate output path
+	pathType := classifyPath(output)
+	if pathType == InvalidPath {
+		return errors.New("Invalid output filepath.")
+	}
+
+	// Without --format the icon paths follow the output rules shared with
+	// library users, single-image extensions are handled below
+	extension := filepath.Ext(output)
+	format, known := extensionFormats[strings.ToLower(extension)]
+	if opts.format == "" && (pathType == DirectoryPath || imageFormats[format] == nil) {
+		err := outputs.ConvertWith(src.path, output, func(icoPath string, icnsPath string) error {
+			return createPaths(src, icoPath, icnsPath, opts)
+		})
+		if errors.As(err, new(*outputs.UnsupportedExtensionError)) {
+			return unsupportedExtensionError{extension: extension}
+		}
+		if errors.Is(err, outputs.ErrInvalidPath) {
+			return errors.New("Invalid output filepath.")
+		}
+		return err
+	}
+
+	// Generate the icons named after the input inside the output directory
+	if pathType == DirectoryPath {
+		return createIcons(src, filepath.Join(output, src.name()), opts.format, opts)
+	}
+
+	// --format overrides the format implied by the extension, which is
+	// replaced if it is a known extension and kept as part of the name otherwise
+	if opts.format != "" {
+		format = opts.format
+	}
+
+	// Image extensions without --format write a single image to the path
+	if imageFormats[format] != nil {
+		if opts.imageSize == 0 {
+			return fmt.Errorf("A %s output needs exactly one size in --sizes.", extension)
 		}
+		opts.format = format
+		return src.createImage(output, opts)
+	}
+
+	base := output
+	if known {
+		base = strings.TrimSuffix(output, extension)
 	}
+	return createIcons(src, base, format, opts)
 }
 
-// showUsage displays the command-line usage information to stderr.
-func showUsage() {
-	fmt.Fprint(os.Stderr, `
+// extensionFormats maps the output extensions (in lower case) to the format
+// they select when --format isn't given: an icon format, "both" for ICO and
+// ICNS, or a single-image format.
+var extensionFormats = map[string]string{
+	".ico":  "ico",
+	".icns": "icns",
+	".icon": "both",
+	"":      "both",
+	".png":  "png",
+	".bmp":  "bmp",
+	".gif":  "gif",
+}
+
+// unsupportedExtensionError is returned for an output file whose extension
+// is missing from extensionFormats when no --format selects the format.
+type unsupportedExtensionError struct {
+	extension string
+}
+
+func (e unsupportedExtensionError) Error() string {
+	return fmt.Sprintf("Unsupported output extension %q, use .ico, .icns, .icon, .png, .bmp, .gif, no extension or --format.", e.extension)
+}
+
+// createIcons writes <base>.ico and/or <base>.icns as selected by format,
+// which is "ico", "icns", or "both" or "" for both. Both formats are
+// attempted even if the first one fails.
+func createIcons(src source, base string, format string, opts options) error {
+	var icoPath, icnsPath string
+	if format != "icns" {
+		icoPath = base + ".ico"
+	}
+	if format != "ico" {
+		icnsPath = base + ".icns"
+	}
+	return createPaths(src, icoPath, icnsPath, opts)
+}
+
+// createPaths writes the ICO file to icoPath and the ICNS file to icnsPath,
+// skipping a format whose path is empty. Both formats are attempted even if
+// the first one fails, and the file that was still written is named in the
+// returned errors.
+func createPaths(src source, icoPath string, icnsPath string, opts options) error {
+	var errs []error
+	var written []string
+	create := func(path string, createFile func(string, options) error) {
+		if path == "" {
+			return
+		}
+		if err := createFile(path, opts); err != nil {
+			errs = append(errs, err)
+			return
+		}
+		written = append(written, path)
+	}
+	create(icoPath, src.createIco)
+	create(icnsPath, src.createIcns)
+
+	if len(errs) > 0 && len(written) > 0 && !opts.dryRun {
+		errs = append(errs, fmt.Errorf("Only %s was written.", strings.Join(written, " and ")))
+	}
+	return errors.Join(errs...)
+}
+
+// createExplicit writes the ICO and ICNS files requested with --ico and
+// --icns, parsing the SVG only once. Both formats are attempted even if the
+// first one fails.
+func createExplicit(src source, opts options) error {
+	icon, err := src.parse()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if opts.icoPath != "" {
+		errs = append(errs, writeIco(icon, opts.icoPath, opts))
+	}
+	if opts.icnsPath != "" {
+		errs = append(errs, writeIcns(icon, opts.icnsPath, opts))
+	}
+	return errors.Join(errs...)
+}
+
+// printError prints err to stderr. Joined errors are printed one per line.
+func printError(err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			printError(err)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "[svg2icon] %s\n", err)
+}
+
+// usageError prints err followed by the usage to stderr and exits with exitUsage.
+func usageError(err error) {
+	fmt.Fprintf(os.Stderr, "[svg2icon] %s\n", err)
+	showUsage(os.Stderr)
+	os.Exit(exitUsage)
+}
+
+// showUsage writes the command-line usage information to w.
+func showUsage(w io.Writer) {
+	fmt.Fprint(w, `
 Usage:
-  svg2icon <input.svg> <output>
+  svg2icon [options] <input.svg> <output>
+  svg2icon [options] <input.svg> [--ico <path>] [--icns <path>]
+  svg2icon [options] --batch "<pattern>" <output-directory>
+  svg2icon [options] --batch <directory> [--recursive] <output-directory>
 
 Behavior:
   - If <output> is an existing directory, <input>.ico and <input>.icns will be created inside it.
+  - Existing files are never overwritten unless --force is given.
   - If <output> ends with ".ico", only the ICO file will be generated.
   - If <output> ends with ".icns", only the ICNS file will be generated.
   - When the <output> ends with ".icon" or has no extension, both files will be created using <output> as the base name.
+  - If <output> ends with ".png", ".bmp" or ".gif", a single image of the size given with --sizes (default 256) is written to it.
+  - Any other extension is rejected unless --format selects the format.
+  - --format=ico, icns or both overrides the extension: a known extension is replaced, any other is kept and the format's extension appended.
+  - With --ico and/or --icns, each format is written to its own path and <output> is omitted.
+  - If <input.svg> is "-", the SVG is read from stdin.
+  - If <output> is "-", the format selected with --format is written to stdout.
+  - With --format=png, bmp or gif, a single image of the size given with --sizes (default 256) is written to <output>.
+  - With --data-uri, the stdout output is written as a base64 data: URI instead.
+  - With --favicon, a web favicon bundle (ICO, PNGs and an HTML snippet) is created inside the <output> directory.
+  - With --android, ic_launcher.png is created in mipmap-mdpi through mipmap-xxxhdpi inside the <output> res directory.
+  - With --msix, the Windows tile and Store logos of an MSIX package are created inside the <output> directory.
+  - With --appstore, the 1024x1024 App Store marketing icon is written to <output> as PNG.
+  - With --png, <input>-<size>.png is created inside the <output> directory for every size given with --sizes.
+  - With --watch, the outputs are regenerated (and overwritten) every time <input.svg> is saved, until interrupted.
+  - With --batch, every SVG matching <pattern> is converted to <name>.ico and <name>.icns inside <output-directory>,
+    or to the file names given with --template. A directory as <pattern> converts every SVG inside it.
+  - With --recursive, the subdirectories of the --batch directory are converted too, mirroring the tree inside <output-directory>.
+
+Options:
+  --sizes=<list>     Comma-separated ICO sizes in pixels (1-256), default 16,24,32,48,64,128,256.
+  --exclude-sizes=<list>
+                     Comma-separated sizes to drop from the ICO sizes (after --sizes) and the ICNS entries.
+  --format=<fmt>     Icon format to write, "ico", "icns" or "both", overriding the output extension (only "ico" or "icns" for stdout),
+                     or a single-image format: "png", "bmp" or "gif".
+  --ico=<path>       Write the ICO file to <path> instead of deriving it from <output>.
+  --icns=<path>      Write the ICNS file to <path> instead of deriving it from <output>.
+  --layer=<id>       Render only the top-level <g> group with the given id.
+  --respect-natural-size
+                     Skip ICO and ICNS sizes above the width and height declared by the SVG.
+  --batch=<glob>     Convert all SVG files matching the glob pattern, or inside the given directory.
+  --recursive        Also convert the SVG files in all subdirectories of the --batch directory.
+  --timeout=<dur>    Abort a conversion that takes longer than the duration, e.g. 30s; in batch mode per file.
+  --concurrency=<n>  Number of files converted at the same time in batch mode, default the number of CPUs.
+  --template=<tmpl>  File names of batch outputs with {name}, {format}, {ext} and {size} placeholders, default "{name}.{ext}".
+  --favicon          Generate favicon.ico, PNG variants and favicons.html for the web.
+  --webp             Add a 32x32 favicon.webp to the favicon bundle.
+  --android          Generate Android launcher icons for every mipmap density.
+  --msix             Generate Windows MSIX tile and Store logos.
+  --appstore         Generate the 1024x1024 App Store marketing icon PNG.
+  --png              Generate one size-suffixed PNG per size given with --sizes instead of icons.
+  --strict           Fail instead of warning when the SVG renders fully transparent or uses unsupported features.
+  --force            Overwrite existing output files.
+  --atomic-write=false
+                     Write outputs in place instead of to a temporary file that is renamed over the output once complete.
+  --data-uri         Write the stdout output as a base64 data: URI.
+  --dry-run          Parse the SVG and print the planned outputs without writing anything.
+  --verbose          Log the start, end, duration and canvas memory of every render to stderr.
+  --watch            Regenerate the outputs whenever the input SVG changes.
+  --cache            Reuse ICO and ICNS images rendered before from the user cache directory.
+  --no-cache         Don't use the cache, even if --cache is given.
+  --json             Print a JSON array describing every written file to stdout.
+  --checksum         Print the SHA-256 of every written file in sha256sum format, or add it to the --json output.
+  --version          Print the version and build information.
+  --doctor           Convert a built-in test SVG to every format in a temporary directory and verify the results.
+  -h, --help         Print this help.
+
+Examples:
+  svg2icon logo.svg ./icons/       Creates ./icons/logo.ico and ./icons/logo.icns
+  svg2icon logo.svg app.ico        Creates app.ico only
+  svg2icon logo.svg app.icns       Creates app.icns only
+  svg2icon logo.svg app.icon       Creates app.ico and app.icns
+  svg2icon logo.svg app            Creates app.ico and app.icns
+  svg2icon --format=icns logo.svg app.ico   Creates app.icns only
+  svg2icon --format=ico logo.svg - > app.ico
+  svg2icon logo.svg --ico out/app.ico --icns out/app.icns
+
+Exit codes:
+  0  Success
+  1  A conversion failed
+  2  Missing, unknown or invalid arguments
 `)
 }
 
+// parseArgs splits the command-line arguments into options and positional arguments.
+// Flags may appear before, between or after the positional arguments.
+func parseArgs(args []string) (options, []string, error) {
+	opts := options{sizes: ico.IconSizes, concurrency: runtime.NumCPU(), atomicWrite: true}
+
+	var sizes, excludeSizes string
+	flags := flag.NewFlagSet("svg2icon", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&sizes, "sizes", "", "")
+	flags.StringVar(&excludeSizes, "exclude-sizes", "", "")
+	flags.StringVar(&opts.format, "format", "", "")
+	flags.StringVar(&opts.batch, "batch", "", "")
+	flags.IntVar(&opts.concurrency, "concurrency", opts.concurrency, "")
+	flags.DurationVar(&opts.timeout, "timeout", 0, "")
+	flags.BoolVar(&opts.favicon, "favicon", false, "")
+	flags.BoolVar(&opts.webp, "webp", false, "")
+	flags.BoolVar(&opts.android, "android", false, "")
+	flags.BoolVar(&opts.msix, "msix", false, "")
+	flags.BoolVar(&opts.appStore, "appstore", false, "")
+	flags.BoolVar(&opts.pngSet, "png", false, "")
+	flags.StringVar(&opts.template, "template", defaultTemplate, "")
+	flags.BoolVar(&opts.recursive, "recursive", false, "")
+	flags.BoolVar(&opts.strict, "strict", false, "")
+	flags.BoolVar(&opts.force, "force", false, "")
+	flags.BoolVar(&opts.atomicWrite, "atomic-write", opts.atomicWrite, "")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "")
+	flags.BoolVar(&opts.dataURI, "data-uri", false, "")
+	flags.BoolVar(&opts.verbose, "verbose", false, "")
+	flags.BoolVar(&opts.version, "version", false, "")
+	flags.BoolVar(&opts.doctor, "doctor", false, "")
+	flags.BoolVar(&opts.watch, "watch", false, "")
+	flags.StringVar(&opts.icoPath, "ico", "", "")
+	flags.StringVar(&opts.icnsPath, "icns", "", "")
+	flags.BoolVar(&opts.json, "json", false, "")
+	flags.BoolVar(&opts.checksum, "checksum", false, "")
+	flags.BoolVar(&opts.cache, "cache", false, "")
+	flags.StringVar(&opts.layer, "layer", "", "")
+	flags.BoolVar(&opts.noCache, "no-cache", false, "")
+	flags.BoolVar(&opts.natural, "respect-natural-size", false, "")
+
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return opts, nil, err
+			}
+			return opts, nil, fmt.Errorf("%s.", err)
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+
+	switch opts.format {
+	case "", "both", "ico", "icns", "png", "bmp", "gif":
+	default:
+		return opts, nil, fmt.Errorf("Invalid format %q, must be \"both\", \"ico\", \"icns\", \"png\", \"bmp\" or \"gif\".", opts.format)
+	}
+	if opts.webp && !opts.favicon {
+		return opts, nil, errors.New("--webp can only be used together with --favicon.")
+	}
+	if opts.explicitPaths() && (opts.batch != "" || opts.favicon || opts.android || opts.msix || opts.appStore || opts.pngSet || imageFormats[opts.format] != nil) {
+		return opts, nil, errors.New("--ico and --icns can't be combined with --batch, --favicon, --android, --msix, --appstore, --png or a single-image --format.")
+	}
+	if opts.json && (opts.dryRun || opts.watch) {
+		return opts, nil, errors.New("--json can't be combined with --dry-run or --watch.")
+	}
+	if opts.checksum && opts.dryRun {
+		return opts, nil, errors.New("--checksum can't be combined with --dry-run.")
+	}
+	if opts.timeout < 0 {
+		return opts, nil, fmt.Errorf("Invalid timeout %s, must be positive.", opts.timeout)
+	}
+	if opts.concurrency < 1 {
+		return opts, nil, fmt.Errorf("Invalid concurrency %d, must be at least 1.", opts.concurrency)
+	}
+	if opts.template != defaultTemplate && opts.batch == "" {
+		return opts, nil, errors.New("--template can only be used with --batch.")
+	}
+	if opts.recursive && opts.batch == "" {
+		return opts, nil, errors.New("--recursive can only be used with --batch.")
+	}
+	if err := validateTemplate(opts.template); err != nil {
+		return opts, nil, err
+	}
+
+	if sizes != "" {
+		parsed, err := parseSizes(sizes)
+		if err != nil {
+			return opts, nil, err
+		}
+		opts.sizes = parsed
+	}
+
+	// Exclusions apply after --sizes selected the ICO sizes
+	if excludeSizes != "" {
+		excluded, err := parseExcludeSizes(excludeSizes)
+		if err != nil {
+			return opts, nil, err
+		}
+		opts, err = excludeIconSizes(opts, excluded)
+		if err != nil {
+			return opts, nil, err
+		}
+	}
+
+	// Single images are rendered at one size, left 0 when --sizes lists
+	// several ICO sizes
+	opts.imageSize = defaultImageSize
+	if sizes != "" {
+		switch {
+		case len(opts.sizes) == 1:
+			opts.imageSize = opts.sizes[0]
+		case imageFormats[opts.format] != nil:
+			return opts, nil, fmt.Errorf("--format=%s needs exactly one size in --sizes.", opts.format)
+		default:
+			opts.imageSize = 0
+		}
+	}
+
+	return opts, positional, nil
+}
+
+// parseSizes parses a comma-separated list of ICO sizes such as "16,32,48".
+// The sizes are returned sorted and without duplicates.
+// Returns an error naming the first entry that isn't a valid size.
+func parseSizes(list string) ([]int, error) {
+	var sizes []int
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		size, err := strconv.Atoi(entry)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid size %q in --sizes.", entry)
+		}
+		if size < ico.MinIconSize || size > ico.MaxIconSize {
+			return nil, fmt.Errorf("Invalid size in --sizes, ICO size must be %d-%d, got %d.", ico.MinIconSize, ico.MaxIconSize, size)
+		}
+		sizes = append(sizes, size)
+	}
+	return ico.NormalizeSizes(sizes), nil
+}
+
+// parseExcludeSizes parses the comma-separated list of --exclude-sizes.
+// Unlike --sizes it accepts the ICNS-only sizes above 256 as well.
+func parseExcludeSizes(list string) ([]int, error) {
+	var sizes []int
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		size, err := strconv.Atoi(entry)
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("Invalid size %q in --exclude-sizes.", entry)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// excludeIconSizes drops the excluded sizes from the ICO sizes and the ICNS
+// entries. Returns an error if no size would be left for a format that may
+// be written.
+func excludeIconSizes(opts options, excluded []int) (options, error) {
+	drop := func(size int) bool {
+		return slices.Contains(excluded, size)
+	}
+	opts.sizes = slices.DeleteFunc(slices.Clone(opts.sizes), drop)
+	opts.icnsTypes = slices.DeleteFunc(slices.Clone(opts.icnsTypesOrStandard()), func(iconType icns.IconType) bool {
+		return drop(iconType.Size)
+	})
+
+	if len(opts.sizes) == 0 && opts.format != "icns" {
+		return opts, errors.New("--exclude-sizes removes every ICO size.")
+	}
+	if len(opts.icnsTypes) == 0 && opts.format != "ico" && imageFormats[opts.format] == nil {
+		return opts, errors.New("--exclude-sizes removes every ICNS size.")
+	}
+	return opts, nil
+}
+
+// writeStdout encodes the format selected with --format and writes it to stdout,
+// as a data: URI if --data-uri is set. Both formats can't be interleaved into
+// a single stream, so a format is required.
+func writeStdout(src source, opts options) error {
+	var data []byte
+	var err error
+
+	switch opts.format {
+	case "ico":
+		if opts.dryRun {
+			printPlan("ico", "- (stdout)", opts.sizes)
+			return nil
+		}
+		data, err = src.encodeIco(opts)
+		if err == nil && opts.dataURI {
+			data = []byte(ico.DataURI(data) + "\n")
+		}
+	case "icns":
+		if opts.dryRun {
+			printPlan("icns", "- (stdout)", opts.icnsSizes())
+			return nil
+		}
+		data, err = src.encodeIcns(opts)
+		if err == nil && opts.dataURI {
+			data = []byte(icns.DataURI(data) + "\n")
+		}
+	case "png", "bmp", "gif":
+		if opts.dryRun {
+			printPlan(opts.format, "- (stdout)", []int{opts.imageSize})
+			return nil
+		}
+		data, err = src.encodeImage(opts)
+		if err == nil && opts.dataURI {
+			data = []byte(imageDataURI(opts.format, data) + "\n")
+		}
+	default:
+		return errors.New("Can't write both ICO and ICNS to stdout, use --format=ico or --format=icns.")
+	}
+	if err != nil {
+		return err
+	}
+	if err := opts.context().Err(); err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// name returns the base name used for output files derived from the source.
+// SVG markup from stdin has no file name, so "icon" is used instead.
+func (s source) name() string {
+	if s.isStdin {
+		return outputs.DefaultName
+	}
+	return outputs.BaseName(s.path)
+}
+
+// encodeIco returns the ICO bytes with the sizes from opts for the source.
+func (s source) encodeIco(opts options) ([]byte, error) {
+	r, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ico.EncodeIcoReaderOptionsContext(opts.context(), r, opts.sizes, opts.icoOptions())
+}
+
+// encodeIcns returns the ICNS bytes for the source.
+func (s source) encodeIcns(opts options) ([]byte, error) {
+	r, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return icns.EncodeIcnsReaderOptionsContext(opts.context(), r, opts.icnsOptions())
+}
+
+// open returns a reader for the SVG markup of the source.
+func (s source) open() (io.ReadCloser, error) {
+	if s.inMemory() {
+		return io.NopCloser(bytes.NewReader(s.data)), nil
+	}
+	return os.Open(s.path)
+}
+
+// parse parses the source into an icon for rendering individual PNGs.
+func (s source) parse() (*oksvg.SvgIcon, error) {
+	if s.inMemory() {
+		return png.ParseSvgReader(bytes.NewReader(s.data))
+	}
+	return png.ParseSvg(s.path)
+}
+
+// createIco writes the ICO file with the sizes from opts for the source to outputPath.
+// An existing file is only replaced when --force is set.
+func (s source) createIco(outputPath string, opts options) error {
+	if err := checkOverwrite(outputPath, opts.force); err != nil {
+		return err
+	}
+	if opts.dryRun {
+		printPlan("ico", outputPath, opts.sizes)
+		return nil
+	}
+	if !s.inMemory() {
+		err := ico.CreateIcoOptionsContext(opts.context(), s.path, outputPath, opts.sizes, opts.icoOptions())
+		if err != nil {
+			return outputError(outputPath, err)
+		}
+		return recordFile(outputPath, "ico", opts.sizes, opts)
+	}
+
+	data, err := s.encodeIco(opts)
+	if err != nil {
+		return err
+	}
+	return writeOutput(outputPath, "ico", opts.sizes, data, opts)
+}
+
+// createIcns writes the ICNS file for the source to outputPath.
+// An existing file is only replaced when --force is set.
+func (s source) createIcns(outputPath string, opts options) error {
+	if err := checkOverwrite(outputPath, opts.force); err != nil {
+		return err
+	}
+	if opts.dryRun {
+		printPlan("icns", outputPath, opts.icnsSizes())
+		return nil
+	}
+	if !s.inMemory() {
+		err := icns.CreateIcnsOptionsContext(opts.context(), s.path, outputPath, opts.icnsOptions())
+		if err != nil {
+			return outputError(outputPath, err)
+		}
+		return recordFile(outputPath, "icns", opts.icnsSizes(), opts)
+	}
+
+	data, err := s.encodeIcns(opts)
+	if err != nil {
+		return err
+	}
+	return writeOutput(outputPath, "icns", opts.icnsSizes(), data, opts)
+}
+
+// writePng renders the parsed icon at size pixels and writes it to path.
+// An existing file is only replaced when --force is set.
+func writePng(icon *oksvg.SvgIcon, path string, size int, opts options) error {
+	if err := checkOverwrite(path, opts.force); err != nil {
+		return err
+	}
+	if opts.dryRun {
+		printPlan("png", path, []int{size})
+		return nil
+	}
+
+	pngData, err := opts.render(func() ([]byte, error) {
+		return png.RenderIconOpts(icon, size, opts.renderOptions())
+	})
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, "png", []int{size}, pngData, opts)
+}
+
+// writeIco encodes the parsed icon as an ICO file with the sizes from opts and
+// writes it to path. An existing file is only replaced when --force is set.
+func writeIco(icon *oksvg.SvgIcon, path string, opts options) error {
+	if err := checkOverwrite(path, opts.force); err != nil {
+		return err
+	}
+	if opts.dryRun {
+		printPlan("ico", path, opts.sizes)
+		return nil
+	}
+
+	data, err := ico.EncodeIcoIconContext(opts.context(), icon, opts.sizes, opts.icoOptions())
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, "ico", opts.sizes, data, opts)
+}
+
+// writeIcns encodes the parsed icon as an ICNS file and writes it to path.
+// An existing file is only replaced when --force is set.
+func writeIcns(icon *oksvg.SvgIcon, path string, opts options) error {
+	if err := checkOverwrite(path, opts.force); err != nil {
+		return err
+	}
+	if opts.dryRun {
+		printPlan("icns", path, opts.icnsSizes())
+		return nil
+	}
+
+	data, err := icns.EncodeIcnsIconContext(opts.context(), icon, opts.icnsOptions())
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, "icns", opts.icnsSizes(), data, opts)
+}
+
+// prepare readies a validated source for conversion: it keeps only the group
+// selected with --layer, warns about (or with --strict rejects) SVGs that use
+// features the rasterizer ignores, and drops sizes above the natural size
+// with --respect-natural-size. Blank renders are caught by withBlankCheck.
+// Returns the options to convert the source with.
+func prepare(src *source, opts options) (options, error) {
+	if err := src.selectLayer(opts.layer); err != nil {
+		return opts, err
+	}
+	if err := checkFeatures(*src, opts); err != nil {
+		return opts, err
+	}
+	return limitToNaturalSize(*src, opts)
+}
+
+// checkFeatures reports every SVG feature of the source that the rasterizer
+// ignores. The features are printed as warnings, or returned as an error
+// with --strict.
+func checkFeatures(src source, opts options) error {
+	data, err := src.markup()
+	if err != nil {
+		return err
+	}
+	features := png.DetectFeatures(data)
+	if len(features) == 0 {
+		return nil
+	}
+
+	if opts.strict {
+		var errs []error
+		for _, feature := range features {
+			errs = append(errs, fmt.Errorf("%s: unsupported %s.", src.displayName(), feature))
+		}
+		return errors.Join(errs...)
+	}
+	for _, feature := range features {
+		opts.warnf("Warning: %s: unsupported %s.", src.displayName(), feature)
+	}
+	return nil
+}
+
+// withBlankCheck runs convert with renders that fail on an SVG without any
+// visible content. The blank render is returned as an error with --strict,
+// otherwise it is printed as a warning and convert runs again keeping the
+// fully transparent icons.
+func withBlankCheck(src source, opts options, convert func(opts options) error) error {
+	err := convert(opts)
+	if !errors.Is(err, png.ErrBlankRender) {
+		return err
+	}
+
+	if opts.strict {
+		return fmt.Errorf("%s: %s.", src.displayName(), png.ErrBlankRender)
+	}
+	opts.warnf("Warning: %s: %s.", src.displayName(), png.ErrBlankRender)
+	opts.allowBlank = true
+	return convert(opts)
+}
+
+// displayName returns the name used for the source in messages.
+func (s source) displayName() string {
+	if s.isStdin {
+		return "stdin"
+	}
+	return s.path
+}
+
+// checkOverwrite returns an error if path already exists, unless force is set.
+func checkOverwrite(path string, force bool) error {
+	if force {
+		return nil
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return fmt.Errorf("%s already exists, use --force to overwrite it.", path)
+	}
+	return nil
+}
+
+// printPlan prints an output that would be written in dry-run mode.
+func printPlan(format string, path string, sizes []int) {
+	var list []string
+	for _, size := range sizes {
+		list = append(list, strconv.Itoa(size))
+	}
+	fmt.Printf("%-5s %s [%s]\n", format, path, strings.Join(list, ", "))
+}
+
 // validSvg validates that the given path points to a readable SVG file.
 // It checks the file extension, existence, accessibility, and basic readability.
 // Returns an error if validation fails.
@@ -132,7 +1156,14 @@ func validSvg(path string) error {
 
 	pathInfo, err := os.Stat(path)
 	if err != nil {
-		return errors.New("Invalid input filepath.")
+		if _, linkErr := os.Lstat(path); linkErr == nil {
+			target, _ := os.Readlink(path)
+			return fmt.Errorf("Input file %s is a broken symbolic link to %s.", path, target)
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("Input file %s does not exist.", path)
+		}
+		return fmt.Errorf("Invalid input filepath %s: %s.", path, errors.Unwrap(err))
 	}
 	if pathInfo.IsDir() {
 		return errors.New("Input filepath can't be a directory.")
@@ -140,7 +1171,7 @@ func validSvg(path string) error {
 
 	file, err := os.Open(path)
 	if err != nil {
-		return errors.New("Can't open inputfile.")
+		return fmt.Errorf("Can't open input file %s: %s.", path, errors.Unwrap(err))
 	}
 	defer file.Close()
 
@@ -169,9 +1200,8 @@ func classifyPath(path string) PathType {
 	}
 
 	base := filepath.Base(path)
-	extension := filepath.Ext(base)
-	name := strings.TrimSuffix(base, extension)
-	if name == "" || extension == "" {
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	if name == "" {
 		return InvalidPath
 	}
 
@@ -0,0 +1,223 @@
+package svg2icon
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testSvg is a small icon used as input by the tests.
+const testSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">
+<circle cx="32" cy="32" r="28" fill="#2b6cb0"/>
+</svg>
+`
+
+func TestOutputError(t *testing.T) {
+	const path = "out/icon.ico"
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "permission denied",
+			err:  &fs.PathError{Op: "open", Path: path, Err: fs.ErrPermission},
+			want: "Can't write out/icon.ico: permission denied, check that the file and its directory are writable.",
+		},
+		{
+			name: "wrapped permission denied",
+			err:  fmt.Errorf("encoding: %w", &fs.PathError{Op: "write", Path: path, Err: fs.ErrPermission}),
+			want: "Can't write out/icon.ico: permission denied, check that the file and its directory are writable.",
+		},
+		{
+			name: "other error",
+			err:  &fs.PathError{Op: "write", Path: path, Err: errors.New("no space left on device")},
+			want: "Can't write out/icon.ico: no space left on device.",
+		},
+		{
+			name: "error about another file",
+			err:  &fs.PathError{Op: "open", Path: "input.svg", Err: fs.ErrPermission},
+			want: "open input.svg: permission denied",
+		},
+		{
+			name: "not a path error",
+			err:  errors.New("invalid ICO size"),
+			want: "invalid ICO size",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := outputError(path, test.err).Error(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// readOnlyDir returns a new directory that can't be written to, skipping the
+// test where permissions aren't enforced, e.g. when running as root.
+func readOnlyDir(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "read-only")
+	if err := os.Mkdir(dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(dir, 0755) })
+
+	probe := filepath.Join(dir, "probe")
+	if err := os.WriteFile(probe, nil, 0644); err == nil {
+		os.Remove(probe)
+		t.Skip("directory permissions aren't enforced")
+	}
+	return dir
+}
+
+func TestCreatePathsPartialFailure(t *testing.T) {
+	dir := t.TempDir()
+	svgPath := filepath.Join(dir, "icon.svg")
+	if err := os.WriteFile(svgPath, []byte(testSvg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := options{sizes: []int{16, 32}, atomicWrite: true}
+
+	tests := []struct {
+		name     string
+		icoDir   func(t *testing.T) string
+		icnsDir  func(t *testing.T) string
+		want     []string // Lines of the error, nil for success
+		icoFile  bool     // The ICO file is written
+		icnsFile bool
+	}{
+		{
+			name:     "both writable",
+			icoDir:   func(t *testing.T) string { return t.TempDir() },
+			icnsDir:  func(t *testing.T) string { return t.TempDir() },
+			icoFile:  true,
+			icnsFile: true,
+		},
+		{
+			name:    "ICO directory read-only",
+			icoDir:  readOnlyDir,
+			icnsDir: func(t *testing.T) string { return t.TempDir() },
+			want: []string{
+				"Can't write {ico}: permission denied, check that the file and its directory are writable.",
+				"Only {icns} was written.",
+			},
+			icnsFile: true,
+		},
+		{
+			name:    "ICNS directory read-only",
+			icoDir:  func(t *testing.T) string { return t.TempDir() },
+			icnsDir: readOnlyDir,
+			want: []string{
+				"Can't write {icns}: permission denied, check that the file and its directory are writable.",
+				"Only {ico} was written.",
+			},
+			icoFile: true,
+		},
+		{
+			name:    "ICO directory missing",
+			icoDir:  func(t *testing.T) string { return filepath.Join(t.TempDir(), "missing") },
+			icnsDir: func(t *testing.T) string { return t.TempDir() },
+			want: []string{
+				"Can't write {ico}: no such file or directory.",
+				"Only {icns} was written.",
+			},
+			icnsFile: true,
+		},
+		{
+			name:    "both directories missing",
+			icoDir:  func(t *testing.T) string { return filepath.Join(t.TempDir(), "missing") },
+			icnsDir: func(t *testing.T) string { return filepath.Join(t.TempDir(), "missing") },
+			want: []string{
+				"Can't write {ico}: no such file or directory.",
+				"Can't write {icns}: no such file or directory.",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			icoPath := filepath.Join(test.icoDir(t), "icon.ico")
+			icnsPath := filepath.Join(test.icnsDir(t), "icon.icns")
+			replacer := strings.NewReplacer("{ico}", icoPath, "{icns}", icnsPath)
+
+			err := createPaths(source{path: svgPath}, icoPath, icnsPath, opts)
+			switch {
+			case test.want == nil && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case test.want != nil:
+				want := replacer.Replace(strings.Join(test.want, "\n"))
+				if err == nil || err.Error() != want {
+					t.Fatalf("got error %v, want %q", err, want)
+				}
+			}
+
+			for path, wantFile := range map[string]bool{icoPath: test.icoFile, icnsPath: test.icnsFile} {
+				if _, err := os.Stat(path); (err == nil) != wantFile {
+					t.Errorf("%s exists %v, want %v", path, err == nil, wantFile)
+				}
+			}
+		})
+	}
+}
+
+func TestWithBlankCheck(t *testing.T) {
+	dir := t.TempDir()
+	blankPath := filepath.Join(dir, "blank.svg")
+	blankSvg := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64"><g/></svg>`
+	if err := os.WriteFile(blankPath, []byte(blankSvg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	iconPath := filepath.Join(dir, "icon.svg")
+	if err := os.WriteFile(iconPath, []byte(testSvg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		svgPath  string
+		strict   bool
+		want     string // Error, empty for success
+		converts int    // Number of conversion attempts
+	}{
+		{"visible", iconPath, true, "", 1},
+		{"blank warning", blankPath, false, "", 2},
+		{"blank strict", blankPath, true, blankPath + ": rendered icon is fully transparent.", 1},
+	}
+	for _, test := range tests {
+		src := source{path: test.svgPath}
+		outDir := t.TempDir()
+		icoPath := filepath.Join(outDir, "icon.ico")
+		icnsPath := filepath.Join(outDir, "icon.icns")
+		opts := options{sizes: []int{16, 32}, strict: test.strict}
+
+		// The real renders detect a blank SVG, there is no separate probe
+		converts := 0
+		err := withBlankCheck(src, opts, func(opts options) error {
+			converts++
+			return createPaths(src, icoPath, icnsPath, opts)
+		})
+
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.want {
+			t.Errorf("%s: got error %q, want %q", test.name, got, test.want)
+		}
+		if converts != test.converts {
+			t.Errorf("%s: converted %d times, want %d", test.name, converts, test.converts)
+		}
+		written := test.want == ""
+		for _, path := range []string{icoPath, icnsPath} {
+			if _, err := os.Stat(path); (err == nil) != written {
+				t.Errorf("%s: %s written %v, want %v", test.name, filepath.Base(path), err == nil, written)
+			}
+		}
+	}
+}
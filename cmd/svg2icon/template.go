@@ -0,0 +1,62 @@
+package svg2icon
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultTemplate names batch outputs after the input file, e.g. logo.ico.
+const defaultTemplate = "{name}.{ext}"
+
+// templatePlaceholder matches a placeholder such as {name} in a --template.
+var templatePlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// templatePlaceholders are the placeholders a --template may contain.
+var templatePlaceholders = map[string]bool{
+	"{name}":   true,
+	"{format}": true,
+	"{ext}":    true,
+	"{size}":   true,
+}
+
+// validateTemplate checks that the --template contains {name}, so every input
+// gets its own files, only known placeholders and no directories, so the
+// outputs stay inside the output directory.
+func validateTemplate(template string) error {
+	if strings.ContainsAny(template, `/\`) {
+		return fmt.Errorf("--template %q must be a file name without directories.", template)
+	}
+	for _, placeholder := range templatePlaceholder.FindAllString(template, -1) {
+		if !templatePlaceholders[placeholder] {
+			return fmt.Errorf("Unknown placeholder %s in --template, use {name}, {format}, {ext} or {size}.", placeholder)
+		}
+	}
+	if !strings.Contains(template, "{name}") {
+		return fmt.Errorf("--template %q must contain {name}.", template)
+	}
+	return nil
+}
+
+// expandTemplate returns the file name for an output of the named input in
+// the given format, whose largest image has size pixels. The extension is
+// appended unless the template places it with {ext}.
+// Returns an error if the file name isn't a single path element inside the
+// output directory, e.g. ".." or one containing a path separator.
+func expandTemplate(template string, name string, format string, size int) (string, error) {
+	fileName := strings.NewReplacer(
+		"{name}", name,
+		"{format}", format,
+		"{ext}", format,
+		"{size}", strconv.Itoa(size),
+	).Replace(template)
+	if !strings.Contains(template, "{ext}") {
+		fileName += "." + format
+	}
+	if fileName != filepath.Base(fileName) || strings.ContainsAny(fileName, `/\`) || fileName == "." || fileName == ".." {
+		return "", fmt.Errorf("--template names the output %q of %s outside the output directory.", fileName, name)
+	}
+	return fileName, nil
+}
@@ -0,0 +1,83 @@
+package svg2icon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string // Error, empty if the template is valid
+	}{
+		{defaultTemplate, ""},
+		{"{name}_{format}", ""},
+		{"{name}-{size}.{ext}", ""},
+		{"icon.{ext}", `--template "icon.{ext}" must contain {name}.`},
+		{"{name}.{type}", "Unknown placeholder {type} in --template, use {name}, {format}, {ext} or {size}."},
+		{"../{name}", `--template "../{name}" must be a file name without directories.`},
+		{"icons/{name}", `--template "icons/{name}" must be a file name without directories.`},
+		{`..\{name}`, `--template "..\\{name}" must be a file name without directories.`},
+	}
+	for _, test := range tests {
+		got := ""
+		if err := validateTemplate(test.template); err != nil {
+			got = err.Error()
+		}
+		if got != test.want {
+			t.Errorf("validateTemplate(%q) = %q, want %q", test.template, got, test.want)
+		}
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		name     string
+		want     string // File name, empty if it leaves the output directory
+	}{
+		{defaultTemplate, "logo", "logo.ico"},
+		{"{name}_{format}", "logo", "logo_ico.ico"},
+		{"{name}-{size}.{ext}", "logo", "logo-256.ico"},
+		{"{name}", "..", "...ico"},
+		{"{name}{ext}", ".", ".ico"},
+		{"../{name}", "logo", ""},
+		{"{name}/{ext}", "logo", ""},
+		{"{name}", "a/b", ""},
+	}
+	for _, test := range tests {
+		got, err := expandTemplate(test.template, test.name, "ico", 256)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("expandTemplate(%q, %q) = %q, want an error", test.template, test.name, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("expandTemplate(%q, %q) = %q, %v, want %q", test.template, test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestConvertFileStaysInOutputDirectory(t *testing.T) {
+	dir := t.TempDir()
+	svgPath := filepath.Join(dir, "logo.svg")
+	if err := os.WriteFile(svgPath, []byte(testSvg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(dir, "out")
+	if err := os.Mkdir(outDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := options{sizes: []int{16}, format: "ico", template: "../{name}.{ext}"}
+	err := convertFile(svgPath, outDir, opts)
+	if err == nil || !strings.Contains(err.Error(), "outside the output directory") {
+		t.Fatalf("got %v, want an error about leaving the output directory", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "logo.ico")); err == nil {
+		t.Error("the ICO was written outside the output directory")
+	}
+}
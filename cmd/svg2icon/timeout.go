@@ -0,0 +1,48 @@
+package svg2icon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+)
+
+// withTimeout runs convert with options whose context expires after
+// --timeout. Every render runs through options.render, which stops waiting
+// for a render as soon as the deadline passes, and every writer checks the
+// context before each file it writes, so the timeout is reported on time and
+// nothing more is written. convert still returns before withTimeout does, so
+// batch mode stays within --concurrency and no goroutine writes to the --json
+// report afterwards; only the abandoned render finishes in the background.
+// Without --timeout convert is called directly.
+func withTimeout(opts options, convert func(opts options) error) error {
+	if opts.timeout <= 0 {
+		return convert(opts)
+	}
+	ctx, cancel := context.WithTimeout(opts.context(), opts.timeout)
+	defer cancel()
+	opts.ctx = ctx
+
+	err := convert(opts)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("Conversion timed out after %s.", opts.timeout)
+	}
+	return err
+}
+
+// context returns the context conversions are cancelled with, which only
+// expires with --timeout.
+func (o options) context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
+}
+
+// render runs render like png.RenderContext with the context of the options,
+// so a render outliving --timeout is abandoned instead of waited for.
+//
+// Returns the rendered data, or the context error once --timeout expired.
+func (o options) render(render func() ([]byte, error)) ([]byte, error) {
+	return png.RenderContext(o.context(), render)
+}
@@ -0,0 +1,45 @@
+package svg2icon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithTimeoutSlowRender(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	want := "Conversion timed out after 50ms."
+
+	// A render that blocks for seconds, or until the test ends
+	release := make(chan struct{})
+	defer close(release)
+	slowRender := func() ([]byte, error) {
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+		return []byte("late"), nil
+	}
+
+	output := filepath.Join(t.TempDir(), "icon.png")
+	start := time.Now()
+	err := withTimeout(options{timeout: timeout}, func(opts options) error {
+		data, err := opts.render(slowRender)
+		if err != nil {
+			return err
+		}
+		return writeOutput(output, "png", []int{16}, data, opts)
+	})
+	elapsed := time.Since(start)
+
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+	if elapsed > timeout+time.Second {
+		t.Errorf("returned %s after the deadline", elapsed-timeout)
+	}
+	if _, err := os.Stat(output); err == nil {
+		t.Error("the late render was written")
+	}
+}
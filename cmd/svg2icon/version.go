@@ -0,0 +1,41 @@
+package svg2icon
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Version is the version of the svg2icon build. Release builds set it with
+// -ldflags "-X github.com/julian-bruyers/svg2icon/cmd/svg2icon.Version=v1.2.3".
+var Version = "dev"
+
+// printVersion prints the version followed by the Go version and the VCS
+// revision embedded by the Go toolchain, if available, to stdout.
+func printVersion() {
+	version := Version
+	info, ok := debug.ReadBuildInfo()
+
+	// Builds installed with "go install ...@v1.2.3" carry the module version
+	if version == "dev" && ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		version = info.Main.Version
+	}
+	fmt.Printf("svg2icon %s\n", version)
+	if !ok {
+		return
+	}
+
+	fmt.Printf("  go:       %s\n", info.GoVersion)
+	settings := make(map[string]string)
+	for _, setting := range info.Settings {
+		settings[setting.Key] = setting.Value
+	}
+	if revision := settings["vcs.revision"]; revision != "" {
+		if settings["vcs.modified"] == "true" {
+			revision += " (modified)"
+		}
+		fmt.Printf("  revision: %s\n", revision)
+	}
+	if built := settings["vcs.time"]; built != "" {
+		fmt.Printf("  time:     %s\n", built)
+	}
+}
@@ -0,0 +1,106 @@
+package svg2icon
+
+import (
+	"context"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+)
+
+// watchDebounce is how long the input must stay unchanged before the outputs
+// are regenerated, so the burst of events of a single save triggers only one
+// regeneration.
+const watchDebounce = 200 * time.Millisecond
+
+// runWatch generates the outputs for src and regenerates them every time the
+// SVG file changes until the process is interrupted.
+//
+// The parent directory is watched instead of the file itself, so the file is
+// still picked up after editors that save by writing a new file and renaming
+// it over the original. Outputs are always overwritten, failed regenerations
+// are printed and watching continues.
+//
+// opts are the options as given on the command line. Every regeneration
+// prepares its own copy, so the sizes dropped for one version of the file
+// are generated again once it grows.
+func runWatch(src source, output string, opts options) error {
+	opts.force = true
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("Can't watch %s: %s.", src.path, err)
+	}
+	defer watcher.Close()
+
+	// Watch the file a symbolic link points to, editors write to the target
+	watched := src.path
+	if resolved, err := filepath.EvalSymlinks(src.path); err == nil {
+		watched = resolved
+	}
+	if err := watcher.Add(filepath.Dir(watched)); err != nil {
+		return fmt.Errorf("Can't watch %s: %s.", src.path, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	regenerate(src, output, opts)
+	fmt.Printf("Watching %s for changes, press Ctrl+C to stop.\n", src.path)
+
+	name := filepath.Clean(watched)
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			// Removing or renaming the file away is followed by a Create once
+			// the editor has written the new version
+			if filepath.Clean(event.Name) == name && event.Has(fsnotify.Write|fsnotify.Create) {
+				debounce = time.After(watchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			printError(err)
+		case <-debounce:
+			debounce = nil
+			regenerate(src, output, opts)
+		}
+	}
+}
+
+// regenerate validates the SVG file and writes all outputs, printing a
+// timestamped line with the outcome.
+func regenerate(src source, output string, opts options) {
+	stamp := time.Now().Format("15:04:05")
+
+	// Start from the file again, a selected layer holds the previous markup
+	src = source{path: src.path}
+	err := validSvg(src.path)
+	if err == nil {
+		err = withTimeout(opts, func(opts options) error {
+			opts, err := prepare(&src, opts)
+			if err != nil {
+				return err
+			}
+			return withBlankCheck(src, opts, func(opts options) error {
+				return convertOutput(src, output, opts)
+			})
+		})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] Regenerating icons from %s failed.\n", stamp, src.path)
+		printError(err)
+		return
+	}
+
+	fmt.Printf("[%s] Regenerated icons from %s\n", stamp, src.path)
+}
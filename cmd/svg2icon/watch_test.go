@@ -0,0 +1,57 @@
+package svg2icon
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/ico"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRegenerateKeepsOriginalSizes(t *testing.T) {
+	dir := t.TempDir()
+	svgPath := filepath.Join(dir, "icon.svg")
+	output := filepath.Join(dir, "icon.ico")
+	opts := options{sizes: []int{16, 32, 64}, natural: true, force: true}
+
+	// The SVG grows between saves, the second regeneration must not be limited
+	// by the natural size of the first
+	tests := []struct {
+		natural int
+		want    []int
+	}{
+		{32, []int{16, 32}},
+		{512, []int{16, 32, 64}},
+	}
+	for _, test := range tests {
+		svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 64 64">
+<circle cx="32" cy="32" r="28" fill="#2b6cb0"/>
+</svg>`, test.natural, test.natural)
+		if err := os.WriteFile(svgPath, []byte(svg), 0644); err != nil {
+			t.Fatal(err)
+		}
+		regenerate(source{path: svgPath}, output, opts)
+
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := ico.ReadIco(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sizes []int
+		for _, entry := range entries {
+			width, _ := entry.Size()
+			sizes = append(sizes, width)
+		}
+		if !slices.Equal(sizes, test.want) {
+			t.Errorf("natural size %d: ICO sizes %v, want %v", test.natural, sizes, test.want)
+		}
+	}
+	if want := []int{16, 32, 64}; !slices.Equal(opts.sizes, want) {
+		t.Errorf("regenerating changed the sizes to %v", opts.sizes)
+	}
+}
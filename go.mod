@@ -3,12 +3,16 @@ module github.com/julian-bruyers/svg2icon
 go 1.24.5
 
 require (
+	github.com/HugoSmits86/nativewebp v1.3.0
+	github.com/fsnotify/fsnotify v1.10.1
 	github.com/srwiley/oksvg v0.0.0-20221011165216-be6e8873101c
 	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef
+	golang.org/x/image v0.24.0
+	golang.org/x/net v0.0.0-20211118161319-6a13c67c3ce4
+	golang.org/x/term v0.13.0
 )
 
 require (
-	golang.org/x/image v0.0.0-20211028202545-6944b10bf410 // indirect
-	golang.org/x/net v0.0.0-20211118161319-6a13c67c3ce4 // indirect
-	golang.org/x/text v0.3.6 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
 )
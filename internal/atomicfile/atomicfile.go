@@ -0,0 +1,115 @@
+// Package atomicfile writes files atomically, so readers such as tools
+// watching an output directory never see a partially written file.
+package atomicfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes data to the file named by name like os.WriteFile, but
+// writes to a temporary file in the same directory first and renames it into
+// place once it is complete. An interrupted write leaves the previous file,
+// or no file, behind instead of a truncated one.
+//
+// An existing file keeps its permissions, new files are created with perm.
+// A symbolic link is followed, so the file it points to is replaced rather
+// than the link itself.
+//
+// Parameters:
+//   - name: Path of the file to write
+//   - data: Complete contents of the file
+//   - perm: Permissions of a newly created file
+//
+// Returns an error naming name if the file can't be written, in which case
+// the temporary file is removed again.
+func WriteFile(name string, data []byte, perm fs.FileMode) error {
+	target := name
+	if resolved, err := filepath.EvalSymlinks(name); err == nil {
+		target = resolved
+	}
+	if info, err := os.Stat(target); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".tmp-*")
+	if err != nil {
+		return writeError(name, err)
+	}
+	tempPath := temp.Name()
+
+	_, err = temp.Write(data)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempPath, perm)
+	}
+	if err == nil {
+		err = os.Rename(tempPath, target)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return writeError(name, err)
+	}
+	return nil
+}
+
+// Write writes the output of write to the file named by name. By default
+// the output is collected and written atomically with WriteFile. With direct
+// it is streamed straight into the file instead, which is then truncated
+// first and removed again if write or closing it fails, so a failed write
+// never leaves a partial file behind either way.
+//
+// Parameters:
+//   - name: Path of the file to write
+//   - perm: Permissions of a newly created file
+//   - direct: Stream into the file in place instead of renaming a temporary file
+//   - write: Writes the complete contents of the file to w
+//
+// Returns the error of write, or an error naming name if the file can't be
+// written.
+func Write(name string, perm fs.FileMode, direct bool, write func(w io.Writer) error) error {
+	if !direct {
+		var buffer bytes.Buffer
+		if err := write(&buffer); err != nil {
+			return err
+		}
+		return WriteFile(name, buffer.Bytes(), perm)
+	}
+
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	// Don't leave a truncated file behind
+	if err != nil {
+		os.Remove(name)
+		return err
+	}
+	return nil
+}
+
+// writeError reports err as a failure to write name, which is the path the
+// caller passed in rather than the temporary file.
+func writeError(name string, err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	} else if linkErr := (*os.LinkError)(nil); errors.As(err, &linkErr) {
+		err = linkErr.Err
+	}
+	return &fs.PathError{Op: "write", Path: name, Err: err}
+}
@@ -0,0 +1,192 @@
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(t *testing.T, dir string) string // Returns the path to write
+		wantPerm fs.FileMode
+		wantLink bool // The path stays a symbolic link
+		wantErr  bool
+	}{
+		{
+			name: "new file",
+			setup: func(t *testing.T, dir string) string {
+				return filepath.Join(dir, "icon.ico")
+			},
+			wantPerm: 0600,
+		},
+		{
+			name: "existing file keeps its permissions",
+			setup: func(t *testing.T, dir string) string {
+				path := filepath.Join(dir, "icon.ico")
+				if err := os.WriteFile(path, []byte("old contents"), 0640); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chmod(path, 0640); err != nil {
+					t.Fatal(err)
+				}
+				return path
+			},
+			wantPerm: 0640,
+		},
+		{
+			name: "symbolic link",
+			setup: func(t *testing.T, dir string) string {
+				target := filepath.Join(dir, "target.ico")
+				if err := os.WriteFile(target, []byte("old contents"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				link := filepath.Join(dir, "icon.ico")
+				if err := os.Symlink("target.ico", link); err != nil {
+					t.Skip(err)
+				}
+				return link
+			},
+			wantPerm: 0600,
+			wantLink: true,
+		},
+		{
+			// Renaming a file over a non-empty directory fails
+			name: "rename fails",
+			setup: func(t *testing.T, dir string) string {
+				path := filepath.Join(dir, "icon.ico")
+				if err := os.MkdirAll(filepath.Join(path, "child"), 0755); err != nil {
+					t.Fatal(err)
+				}
+				return path
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing directory",
+			setup: func(t *testing.T, dir string) string {
+				return filepath.Join(dir, "missing", "icon.ico")
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := test.setup(t, dir)
+			data := []byte("new contents")
+
+			err := WriteFile(path, data, 0600)
+			if test.wantErr {
+				var pathErr *fs.PathError
+				if !errors.As(err, &pathErr) || pathErr.Op != "write" || pathErr.Path != path {
+					t.Errorf("got %v, want a write error for %s", err, path)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != string(data) {
+					t.Errorf("file holds %q, want %q", got, data)
+				}
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if perm := info.Mode().Perm(); perm != test.wantPerm {
+					t.Errorf("permissions %v, want %v", perm, test.wantPerm)
+				}
+				info, err = os.Lstat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if isLink := info.Mode()&fs.ModeSymlink != 0; isLink != test.wantLink {
+					t.Errorf("path is a symbolic link %v, want %v", isLink, test.wantLink)
+				}
+			}
+
+			// No temporary file is left behind either way
+			temps, err := filepath.Glob(filepath.Join(dir, ".*.tmp-*"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(temps) != 0 {
+				t.Errorf("temporary files left behind: %v", temps)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	errEncode := errors.New("encoding failed")
+	tests := []struct {
+		name      string
+		direct    bool
+		write     func(w io.Writer) error
+		want      string // Contents afterwards, empty if the file is removed
+		wantError error
+	}{
+		{"atomic", false, writeString("new contents"), "new contents", nil},
+		{"direct", true, writeString("new contents"), "new contents", nil},
+		{"atomic failure keeps the old file", false, failAfter("partial", errEncode), "old contents", errEncode},
+		{"direct failure removes the partial file", true, failAfter("partial", errEncode), "", errEncode},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "icon.icns")
+			if err := os.WriteFile(path, []byte("old contents"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := Write(path, 0644, test.direct, test.write)
+			if !errors.Is(err, test.wantError) {
+				t.Fatalf("got %v, want %v", err, test.wantError)
+			}
+			got, err := os.ReadFile(path)
+			switch {
+			case test.want == "" && !errors.Is(err, fs.ErrNotExist):
+				t.Errorf("file wasn't removed: %q, %v", got, err)
+			case test.want != "" && string(got) != test.want:
+				t.Errorf("file holds %q, want %q", got, test.want)
+			}
+
+			// No temporary file is left behind
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if entry.Name() != "icon.icns" {
+					t.Errorf("left %s behind", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+// writeString returns a write function writing s.
+func writeString(s string) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
+
+// failAfter returns a write function writing s and then failing with err.
+func failAfter(s string, err error) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		if _, writeErr := io.WriteString(w, s); writeErr != nil {
+			return writeErr
+		}
+		return err
+	}
+}
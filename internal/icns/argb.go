@@ -0,0 +1,121 @@
+package icns
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"github.com/srwiley/oksvg"
+	"image"
+	"image/color"
+)
+
+// ARGBIconTypes defines the small icon types that recent macOS versions read
+// as 32-bit ARGB bitmaps and prefer over PNG in the menu bar and other small
+// UI contexts.
+// Every entry holds the "ARGB" magic followed by the RLE-compressed alpha,
+// red, green and blue channels one after another.
+var ARGBIconTypes = []IconType{
+	{OSType: "icsb", Size: 18}, // 18x18
+	{OSType: "ic04", Size: 16}, // 16x16
+	{OSType: "ic05", Size: 32}, // 16x16@2x
+}
+
+// argbMagic starts the data of every ARGB entry.
+var argbMagic = []byte("ARGB")
+
+// renderARGBEntries renders an ARGB entry for every type in ARGBIconTypes,
+// stopping as soon as ctx is cancelled. Progress is reported after each entry.
+func renderARGBEntries(ctx context.Context, icon *oksvg.SvgIcon, opts Options) ([]IconEntry, error) {
+	renderOpts := png.DefaultRenderOptions()
+	renderOpts.Logger = opts.Logger
+	renderOpts.PerSize = opts.PerSize
+	renderOpts.RejectBlank = opts.RejectBlank
+
+	var entries []IconEntry
+	for _, iconType := range ARGBIconTypes {
+		data, err := png.RenderContext(ctx, func() ([]byte, error) {
+			canvas, err := png.RasterizeIcon(icon, iconType.Size, renderOpts)
+			if err == nil && opts.PostProcess != nil {
+				err = opts.PostProcess(iconType.Size, canvas)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return encodeARGB(canvas), nil
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("rendering icns entry %s (%dpx): %w", iconType.OSType, iconType.Size, err)
+		}
+		entries = append(entries, newIconEntry(iconType.OSType, data))
+		if opts.Progress != nil {
+			opts.Progress(len(entries), len(ARGBIconTypes))
+		}
+	}
+	return entries, nil
+}
+
+// encodeARGB encodes an image as the data of an ARGB entry: the magic
+// followed by the RLE-compressed alpha, red, green and blue channels.
+// The color channels hold straight, not premultiplied, values.
+func encodeARGB(img *image.RGBA) []byte {
+	bounds := img.Bounds()
+	pixels := bounds.Dx() * bounds.Dy()
+	channels := [4][]byte{}
+	for i := range channels {
+		channels[i] = make([]byte, 0, pixels)
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			channels[0] = append(channels[0], pixel.A)
+			channels[1] = append(channels[1], pixel.R)
+			channels[2] = append(channels[2], pixel.G)
+			channels[3] = append(channels[3], pixel.B)
+		}
+	}
+
+	data := append([]byte{}, argbMagic...)
+	for _, channel := range channels {
+		data = append(data, compressRLE(channel)...)
+	}
+	return data
+}
+
+// decodeARGB decodes the data of an ARGB entry with the given pixel size.
+// It is the inverse of encodeARGB.
+func decodeARGB(osType string, size int, data []byte) (*image.NRGBA, error) {
+	if !bytes.HasPrefix(data, argbMagic) {
+		return nil, fmt.Errorf("icns entry %s: missing ARGB magic", osType)
+	}
+	pixels := size * size
+	channels, err := decompressRLE(data[len(argbMagic):], 4*pixels)
+	if err != nil {
+		return nil, fmt.Errorf("icns entry %s: %w", osType, err)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for i := range pixels {
+		img.SetNRGBA(i%size, i/size, color.NRGBA{
+			A: channels[i],
+			R: channels[pixels+i],
+			G: channels[2*pixels+i],
+			B: channels[3*pixels+i],
+		})
+	}
+	return img, nil
+}
+
+// findARGBType returns the ARGB icon type with the given OSType code.
+func findARGBType(osType string) (IconType, bool) {
+	for _, iconType := range ARGBIconTypes {
+		if iconType.OSType == osType {
+			return iconType, true
+		}
+	}
+	return IconType{}, false
+}
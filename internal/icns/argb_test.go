@@ -0,0 +1,105 @@
+package icns
+
+import (
+	"bytes"
+	"fmt"
+	svgpng "github.com/julian-bruyers/svg2icon/internal/png"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateIcnsARGB(t *testing.T) {
+	svgPath := writeSvg(t, testSvg)
+	output := filepath.Join(t.TempDir(), "icon.icns")
+
+	type call struct{ done, total int }
+	var calls []call
+	opts := Options{ARGB: true, Progress: func(done, total int) {
+		calls = append(calls, call{done, total})
+	}}
+	if err := CreateIcnsOptions(svgPath, output, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every PNG and ARGB entry is counted once against the same total
+	total := len(StandardIconTypes) + len(ARGBIconTypes)
+	if len(calls) != total {
+		t.Fatalf("Progress called %d times, want %d: %v", len(calls), total, calls)
+	}
+	for i, c := range calls {
+		if c != (call{i + 1, total}) {
+			t.Errorf("Progress call %d is %d/%d, want %d/%d", i, c.done, c.total, i+1, total)
+		}
+	}
+
+	// The ARGB entries come first
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ReadIcns(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, argbType := range ARGBIconTypes {
+		if osType := string(entries[i].OSType[:]); osType != argbType.OSType {
+			t.Errorf("entry %d is %q, want %q", i, osType, argbType.OSType)
+		}
+	}
+
+	// Extracted, every ARGB entry holds the straight colors of the render
+	outDir := t.TempDir()
+	if err := ExtractIcns(output, outDir); err != nil {
+		t.Fatal(err)
+	}
+	icon, err := svgpng.ParseSvg(svgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, argbType := range ARGBIconTypes {
+		name := fmt.Sprintf("%s_%dx%d.png", argbType.OSType, argbType.Size, argbType.Size)
+		got := decodePngFile(t, filepath.Join(outDir, name))
+		want, err := svgpng.RasterizeIcon(icon, argbType.Size, svgpng.DefaultRenderOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Bounds() != want.Bounds() {
+			t.Fatalf("%s is %v, want %v", name, got.Bounds(), want.Bounds())
+		}
+
+		visible := 0
+		for y := 0; y < argbType.Size; y++ {
+			for x := 0; x < argbType.Size; x++ {
+				wantPixel := color.NRGBAModel.Convert(want.At(x, y))
+				if gotPixel := got.At(x, y); gotPixel != wantPixel {
+					t.Fatalf("%s: pixel %d,%d is %v, want %v", name, x, y, gotPixel, wantPixel)
+				}
+				if wantPixel.(color.NRGBA).A > 0 {
+					visible++
+				}
+			}
+		}
+		if visible == 0 {
+			t.Errorf("%s is blank", name)
+		}
+	}
+}
+
+// decodePngFile decodes the PNG file at path.
+func decodePngFile(t *testing.T, path string) image.Image {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
@@ -0,0 +1,34 @@
+package icns
+
+import (
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"github.com/srwiley/oksvg"
+	"io"
+)
+
+// parseSvg parses the SVG file, remembering the content hash of its markup in
+// opts for the cache. Unsupported features are checked in the same markup
+// when opts asks for them to be rejected or logged.
+func parseSvg(svgPath string, opts *Options) (*oksvg.SvgIcon, error) {
+	strict, logger := opts.featureCheck()
+	icon, hash, err := png.ParseSvgChecked(svgPath, strict, logger)
+	opts.svgHash = hash
+	return icon, err
+}
+
+// parseSvgReader parses SVG markup read from r like parseSvg.
+func parseSvgReader(r io.Reader, opts *Options) (*oksvg.SvgIcon, error) {
+	strict, logger := opts.featureCheck()
+	icon, hash, err := png.ParseSvgReaderChecked(r, strict, logger)
+	opts.svgHash = hash
+	return icon, err
+}
+
+// featureCheck returns how parsed markup is checked for unsupported
+// features: rejected with strict, or reported to the logger if it isn't nil.
+func (o Options) featureCheck() (strict bool, logger png.Logger) {
+	if o.SkipFeatureCheck {
+		return false, nil
+	}
+	return o.Strict, o.Logger
+}
@@ -0,0 +1,28 @@
+package icns
+
+import (
+	"encoding/base64"
+)
+
+// MediaType is the media type commonly used for ICNS files.
+const MediaType = "image/icns"
+
+// IcnsDataURI generates a macOS ICNS file from an SVG source and returns it as
+// a base64 data: URI.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//
+// Returns the data URI, or an error if SVG processing fails.
+func IcnsDataURI(svgPath string) (string, error) {
+	data, err := EncodeIcns(svgPath)
+	if err != nil {
+		return "", err
+	}
+	return DataURI(data), nil
+}
+
+// DataURI returns encoded ICNS data as a base64 data: URI.
+func DataURI(data []byte) string {
+	return "data:" + MediaType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
@@ -0,0 +1,178 @@
+package icns
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/atomicfile"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// pngSignature starts every PNG-compressed ICNS entry.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// ExtractIcns writes the images of an ICNS file as separate PNG files.
+//
+// PNG entries are written as they are, named like CreateIconset names them
+// (icon_16x16.png, icon_16x16@2x.png, ...). Entries without an iconset name
+// and the classic RLE entries, which are decoded together with their mask,
+// are named after their OSType and dimensions (e.g. icp6_64x64.png,
+// is32_16x16.png), as are the decoded ARGB entries (e.g. ic04_16x16.png).
+// Other entries are skipped. The output directory is created
+// if it doesn't exist.
+//
+// Parameters:
+//   - icnsPath: Path to the ICNS file
+//   - outDir: Directory the PNG files are written to
+//
+// Returns an error if the ICNS file can't be read or parsed, or if a legacy
+// entry can't be decoded or file writing fails.
+func ExtractIcns(icnsPath string, outDir string) error {
+	icnsFile, err := os.Open(icnsPath)
+	if err != nil {
+		return err
+	}
+	defer icnsFile.Close()
+
+	entries, err := ReadIcns(icnsFile)
+	if err != nil {
+		return fmt.Errorf("%s: %w", icnsPath, err)
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+
+	// Masks are looked up by the color entries they belong to
+	entryData := make(map[string][]byte)
+	for _, entry := range entries {
+		entryData[string(entry.OSType[:])] = entry.Data
+	}
+
+	for _, entry := range entries {
+		osType := string(entry.OSType[:])
+
+		var name string
+		var data []byte
+		if bytes.HasPrefix(entry.Data, pngSignature) && len(entry.Data) >= 24 {
+			// The IHDR chunk holds the dimensions right after the signature
+			width := binary.BigEndian.Uint32(entry.Data[16:20])
+			height := binary.BigEndian.Uint32(entry.Data[20:24])
+			name = iconsetNames[osType]
+			if name == "" {
+				name = fmt.Sprintf("%s_%dx%d.png", osType, width, height)
+			}
+			data = entry.Data
+		} else if legacyType, ok := findLegacyType(osType); ok {
+			mask, ok := entryData[legacyType.MaskType]
+			if !ok {
+				return fmt.Errorf("icns entry %s has no %s mask", osType, legacyType.MaskType)
+			}
+			img, err := decodeLegacy(legacyType, entry.Data, mask)
+			if err != nil {
+				return err
+			}
+
+			var buffer bytes.Buffer
+			if err := png.Encode(&buffer, img); err != nil {
+				return err
+			}
+			name = fmt.Sprintf("%s_%dx%d.png", osType, legacyType.Size, legacyType.Size)
+			data = buffer.Bytes()
+		} else if argbType, ok := findARGBType(osType); ok {
+			img, err := decodeARGB(osType, argbType.Size, entry.Data)
+			if err != nil {
+				return err
+			}
+
+			var buffer bytes.Buffer
+			if err := png.Encode(&buffer, img); err != nil {
+				return err
+			}
+			name = fmt.Sprintf("%s_%dx%d.png", osType, argbType.Size, argbType.Size)
+			data = buffer.Bytes()
+		} else {
+			continue
+		}
+
+		err = atomicfile.WriteFile(filepath.Join(outDir, name), data, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findLegacyType returns the legacy icon type whose color entry uses osType.
+func findLegacyType(osType string) (LegacyIconType, bool) {
+	for _, legacyType := range LegacyIconTypes {
+		if legacyType.ColorType == osType {
+			return legacyType, true
+		}
+	}
+	return LegacyIconType{}, false
+}
+
+// decodeLegacy combines the RLE-compressed color data and the alpha mask of
+// a classic ICNS entry into an image. It is the inverse of encodeLegacy.
+func decodeLegacy(legacyType LegacyIconType, colorData []byte, mask []byte) (*image.NRGBA, error) {
+	pixels := legacyType.Size * legacyType.Size
+	// it32 data starts with four zero bytes
+	if legacyType.ColorType == "it32" && len(colorData) >= 4 {
+		colorData = colorData[4:]
+	}
+
+	channels, err := decompressRLE(colorData, 3*pixels)
+	if err != nil {
+		return nil, fmt.Errorf("icns entry %s: %w", legacyType.ColorType, err)
+	}
+	if len(mask) != pixels {
+		return nil, fmt.Errorf("icns entry %s: mask has %d bytes, expected %d", legacyType.MaskType, len(mask), pixels)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, legacyType.Size, legacyType.Size))
+	for i := range pixels {
+		img.SetNRGBA(i%legacyType.Size, i/legacyType.Size, color.NRGBA{
+			R: channels[i],
+			G: channels[pixels+i],
+			B: channels[2*pixels+i],
+			A: mask[i],
+		})
+	}
+	return img, nil
+}
+
+// decompressRLE decodes ICNS run-length encoded data into exactly size bytes.
+// It is the inverse of compressRLE.
+func decompressRLE(data []byte, size int) ([]byte, error) {
+	out := make([]byte, 0, size)
+	for i := 0; i < len(data) && len(out) < size; {
+		control := int(data[i])
+		i++
+		if control < 0x80 {
+			// control+1 literal bytes follow
+			n := control + 1
+			if i+n > len(data) {
+				return nil, errors.New("truncated RLE data")
+			}
+			out = append(out, data[i:i+n]...)
+			i += n
+		} else {
+			// The next byte is repeated control-0x80+3 times
+			if i >= len(data) {
+				return nil, errors.New("truncated RLE data")
+			}
+			out = append(out, bytes.Repeat(data[i:i+1], control-0x80+3)...)
+			i++
+		}
+	}
+	if len(out) != size {
+		return nil, fmt.Errorf("RLE data decodes to %d bytes, expected %d", len(out), size)
+	}
+	return out, nil
+}
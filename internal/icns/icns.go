@@ -6,9 +6,16 @@ package icns
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/atomicfile"
 	"github.com/julian-bruyers/svg2icon/internal/png"
-	"os"
+	"github.com/srwiley/oksvg"
+	"image"
+	"io"
+	"math"
 )
 
 // StandardIconTypes defines the set of icons to be included in the .icns file.
@@ -29,6 +36,12 @@ var StandardIconTypes = []IconType{
 	{OSType: "ic14", Size: 512}, // 256x256@2x
 }
 
+// The range of sizes the ICNS OSType codes represent
+const (
+	MinIconSize = 16
+	MaxIconSize = 1024
+)
+
 // IconType represents an ICNS icon type with its OSType code and size
 type IconType struct {
 	OSType   string
@@ -36,6 +49,99 @@ type IconType struct {
 	IsRetina bool
 }
 
+// Options controls optional parts of a generated ICNS file.
+// The zero value produces the minimal file written by CreateIcns.
+type Options struct {
+	// TOC prepends a 'TOC ' table of contents entry listing the OSType and
+	// length of every icon entry, which some strict parsers and indexing
+	// tools expect.
+	TOC bool
+
+	// Logger receives a message when each icon type starts and finishes
+	// rendering. A nil Logger disables logging.
+	Logger png.Logger
+
+	// CompressionLevel sets how hard the PNG entries are compressed, which
+	// matters most for the 1024x1024 entry. The zero value is
+	// png.DefaultCompression.
+	CompressionLevel png.CompressionLevel
+
+	// Progress is called after each icon type has been rendered with the
+	// number of rendered types so far and the total number of types. With
+	// ARGB the ARGB entries are rendered last and count towards the total.
+	// A nil Progress is skipped.
+	Progress func(done, total int)
+
+	// CacheDir enables an on-disk cache of rendered images in the given
+	// directory (see png.DefaultCacheDir). Entries are keyed by a hash of the
+	// SVG markup, the size and the render options. Icons passed in already
+	// parsed are never cached. An empty CacheDir disables caching.
+	CacheDir string
+
+	// Types are the icon types written to the file. A nil Types writes
+	// StandardIconTypes.
+	Types []IconType
+
+	// Strict fails the conversion with png.ErrUnsupportedFeature when the SVG
+	// uses features the rasterizer ignores (see png.DetectFeatures). Without
+	// it they are reported to the Logger and the conversion proceeds.
+	// Icons passed in already parsed are not checked.
+	Strict bool
+
+	// SkipFeatureCheck leaves out the check of Strict and the messages about
+	// unsupported features to the Logger, for callers that already checked
+	// the markup with png.CheckFeatures themselves. The Logger still
+	// receives the render messages.
+	SkipFeatureCheck bool
+
+	// RejectBlank makes the conversion fail with png.ErrBlankRender when an
+	// entry renders fully transparent, see png.RenderOptions.RejectBlank.
+	RejectBlank bool
+
+	// PostProcess is called with the canvas of every rendered PNG entry
+	// before it is encoded and may modify it in place, see
+	// png.RenderOptions.PostProcess. Entries sharing a pixel size are
+	// processed once. An error aborts the conversion.
+	PostProcess func(size int, img *image.RGBA) error
+
+	// ARGB adds the small ARGBIconTypes entries (icsb, ic04 and ic05), stored
+	// as RLE-compressed 32-bit ARGB instead of PNG, ahead of the PNG entries.
+	ARGB bool
+
+	// Order selects the order of the PNG entries in the file, e.g.
+	// OrderLargestFirst to have readers that use the first matching entry
+	// pick the highest resolution. ARGB entries always come first.
+	// The zero value is OrderAsListed.
+	Order Order
+
+	// DirectWrite streams the file straight to the output path, see
+	// atomicfile.Write. By default it is written to a temporary file in the
+	// same directory and renamed into place once complete, so readers never
+	// see a partial icon.
+	DirectWrite bool
+
+	// PerSize overrides the render options for specific pixel sizes, e.g.
+	// to supersample only the small sizes, see png.RenderOptions.PerSize.
+	// Sizes without an entry are rendered with png.DefaultRenderOptions.
+	PerSize map[int]png.RenderOptions
+
+	// BitDepth sets the bits per channel of the PNG entries, 8 or 16, see
+	// png.RenderOptions.BitDepth. 16 bits mainly benefit the large entries,
+	// which are best given supersampled PerSize options with the same
+	// BitDepth. ARGB entries always have 8 bits. The zero value means 8.
+	BitDepth int
+
+	svgHash string // Content hash of the parsed SVG markup, for the cache
+}
+
+// types returns the icon types selected by the options.
+func (o Options) types() []IconType {
+	if o.Types == nil {
+		return StandardIconTypes
+	}
+	return o.Types
+}
+
 // IconEntry represents a single icon entry in the ICNS file
 type IconEntry struct {
 	OSType [4]byte
@@ -55,58 +161,397 @@ type IconEntry struct {
 //
 // Returns an error if SVG processing or file writing fails.
 func CreateIcns(svgPath string, outputPath string) error {
-	var entries []IconEntry
+	return CreateIcnsTypes(svgPath, outputPath, StandardIconTypes)
+}
 
-	// Generate png byte array for icon types
-	for _, iconType := range StandardIconTypes {
-		pngData, err := png.SvgToPng(svgPath, iconType.Size)
+// CreateIcnsTypes generates a macOS ICNS file from an SVG source containing
+// only the given icon types.
+//
+// Leaving out the large 512x512 and 1024x1024 entries shrinks the file
+// considerably for apps that never display their icon at those sizes.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - outputPath: Path where the ICNS file will be written
+//   - types: Icon types to embed, in file order
+//
+// Returns an error if a type is invalid or SVG processing or file writing fails.
+func CreateIcnsTypes(svgPath string, outputPath string, types []IconType) error {
+	data, _, err := encodeIcns(context.Background(), svgPath, types, Options{})
+	if err != nil {
+		return err
+	}
+
+	// Write the encoded icon to the output file
+	err = atomicfile.WriteFile(outputPath, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateIcnsContext generates a macOS ICNS file like CreateIcns but stops as soon
+// as ctx is cancelled.
+//
+// A render still running when ctx is cancelled is abandoned, so the call
+// returns right away and a cancelled conversion never starts another
+// (potentially expensive) render.
+//
+// Returns the context error if ctx is cancelled, or an error if SVG processing
+// or file writing fails.
+func CreateIcnsContext(ctx context.Context, svgPath string, outputPath string) error {
+	data, _, err := encodeIcns(ctx, svgPath, StandardIconTypes, Options{})
+	if err != nil {
+		return err
+	}
+
+	// Write the encoded icon to the output file
+	err = atomicfile.WriteFile(outputPath, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateIcnsOptions generates a macOS ICNS file like CreateIcns using the
+// given options.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - outputPath: Path where the ICNS file will be written
+//   - opts: Options controlling the file layout
+//
+// Returns an error if SVG processing or file writing fails.
+func CreateIcnsOptions(svgPath string, outputPath string, opts Options) error {
+	return CreateIcnsOptionsContext(context.Background(), svgPath, outputPath, opts)
+}
+
+// CreateIcnsOptionsContext generates a macOS ICNS file like CreateIcnsOptions
+// but stops as soon as ctx is cancelled.
+//
+// A render still running when ctx is cancelled is abandoned, and the context
+// is checked again before the file is written, so a cancelled conversion
+// writes nothing.
+//
+// Returns the context error if ctx is cancelled, or an error if SVG processing
+// or file writing fails.
+func CreateIcnsOptionsContext(ctx context.Context, svgPath string, outputPath string, opts Options) error {
+	data, _, err := encodeIcns(ctx, svgPath, opts.types(), opts)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Write the encoded icon to the output file
+	return atomicfile.Write(outputPath, 0644, opts.DirectWrite, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// EncodeIcns generates a macOS ICNS file from an SVG source and returns its bytes
+// instead of writing them to disk.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//
+// Returns the complete ICNS byte stream, or an error if SVG processing fails.
+func EncodeIcns(svgPath string) ([]byte, error) {
+	data, _, err := encodeIcns(context.Background(), svgPath, StandardIconTypes, Options{})
+	return data, err
+}
+
+// EncodeIcnsReader generates a macOS ICNS file from SVG markup read from r
+// and returns its bytes.
+//
+// Parameters:
+//   - r: Reader providing the SVG markup
+//
+// Returns the complete ICNS byte stream, or an error if SVG processing fails.
+func EncodeIcnsReader(r io.Reader) ([]byte, error) {
+	return EncodeIcnsReaderOptions(r, Options{})
+}
+
+// EncodeIcnsReaderOptions generates a macOS ICNS file from SVG markup read
+// from r like EncodeIcnsReader using the given options.
+//
+// Parameters:
+//   - r: Reader providing the SVG markup
+//   - opts: Options controlling the file layout
+//
+// Returns the complete ICNS byte stream, or an error if SVG processing fails.
+func EncodeIcnsReaderOptions(r io.Reader, opts Options) ([]byte, error) {
+	return EncodeIcnsReaderOptionsContext(context.Background(), r, opts)
+}
+
+// EncodeIcnsReaderOptionsContext generates a macOS ICNS file like
+// EncodeIcnsReaderOptions but stops as soon as ctx is cancelled.
+//
+// A render still running when ctx is cancelled is abandoned, so the call
+// returns right away.
+//
+// Returns the complete ICNS byte stream, the context error if ctx is
+// cancelled, or an error if SVG processing fails.
+func EncodeIcnsReaderOptionsContext(ctx context.Context, r io.Reader, opts Options) ([]byte, error) {
+	icon, err := parseSvgReader(r, &opts)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := encodeIcon(ctx, icon, opts.types(), opts)
+	return data, err
+}
+
+// CreateIcnsFromBytes generates a macOS ICNS file from SVG markup held in
+// memory and returns its bytes, for callers such as web services that never
+// touch the file system.
+//
+// Parameters:
+//   - svg: SVG markup
+//
+// Returns the complete ICNS byte stream, or an error if SVG processing fails.
+func CreateIcnsFromBytes(svg []byte) ([]byte, error) {
+	return EncodeIcnsReader(bytes.NewReader(svg))
+}
+
+// EncodeIcnsIcon generates a macOS ICNS file from an already parsed icon and
+// returns its bytes, so one parsed SVG can be encoded into several formats.
+//
+// Parameters:
+//   - icon: Icon parsed with png.ParseSvg or png.ParseSvgReader
+//   - opts: Options controlling the file layout
+//
+// Returns the complete ICNS byte stream, or an error if rendering fails.
+func EncodeIcnsIcon(icon *oksvg.SvgIcon, opts Options) ([]byte, error) {
+	return EncodeIcnsIconContext(context.Background(), icon, opts)
+}
+
+// EncodeIcnsIconContext generates a macOS ICNS file from an already parsed
+// icon like EncodeIcnsIcon but stops as soon as ctx is cancelled.
+//
+// A render still running when ctx is cancelled is abandoned, so the call
+// returns right away.
+//
+// Returns the complete ICNS byte stream, the context error if ctx is
+// cancelled, or an error if rendering fails.
+func EncodeIcnsIconContext(ctx context.Context, icon *oksvg.SvgIcon, opts Options) ([]byte, error) {
+	data, _, err := encodeIcon(ctx, icon, opts.types(), opts)
+	return data, err
+}
+
+// encodeIcns parses the SVG file and encodes it as an ICNS file.
+func encodeIcns(ctx context.Context, svgPath string, types []IconType, opts Options) ([]byte, IcnsResult, error) {
+	if err := ValidateTypes(types); err != nil {
+		return nil, IcnsResult{}, err
+	}
+
+	// Parse the SVG once and reuse it for every icon type
+	icon, err := parseSvg(svgPath, &opts)
+	if err != nil {
+		return nil, IcnsResult{}, err
+	}
+
+	return encodeIcon(ctx, icon, types, opts)
+}
+
+// encodeIcon renders and encodes an ICNS file holding the given icon types
+// from a parsed icon, stopping as soon as ctx is cancelled.
+// Returns the encoded file together with a description of its entries.
+func encodeIcon(ctx context.Context, icon *oksvg.SvgIcon, types []IconType, opts Options) ([]byte, IcnsResult, error) {
+	if err := ValidateTypes(types); err != nil {
+		return nil, IcnsResult{}, err
+	}
+
+	// Report the progress of the PNG and ARGB entries against one total
+	total := len(types)
+	if opts.ARGB {
+		total += len(ARGBIconTypes)
+	}
+	progress := opts.Progress
+	if progress != nil {
+		opts.Progress = func(done, _ int) { progress(done, total) }
+	}
+
+	entries, err := renderEntries(ctx, icon, opts.Order.apply(types), opts)
+	if err != nil {
+		return nil, IcnsResult{}, err
+	}
+	if opts.ARGB {
+		if progress != nil {
+			opts.Progress = func(done, _ int) { progress(len(types)+done, total) }
+		}
+		argbEntries, err := renderARGBEntries(ctx, icon, opts)
 		if err != nil {
-			return err
+			return nil, IcnsResult{}, err
 		}
+		entries = append(argbEntries, entries...)
+	}
+
+	fileEntries := entries
+	if opts.TOC {
+		fileEntries = append([]IconEntry{newTocEntry(entries)}, entries...)
+	}
+
+	// Generate the complete ICNS file in a buffer.
+	buffer := &bytes.Buffer{}
+	if err := writeIcns(buffer, fileEntries); err != nil {
+		return nil, IcnsResult{}, err
+	}
+
+	return buffer.Bytes(), newIcnsResult(entries, buffer.Len()), nil
+}
+
+// renderEntries renders a PNG entry for every icon type, stopping as soon as
+// ctx is cancelled, even in the middle of a render. Several OSTypes share a
+// pixel size (ic11 and icp5 are both 32px), so every size is rendered once and
+// its PNG bytes are reused for all types of that size.
+func renderEntries(ctx context.Context, icon *oksvg.SvgIcon, types []IconType, opts Options) ([]IconEntry, error) {
+	var entries []IconEntry
 
-		var osTypeBytes [4]byte
-		copy(osTypeBytes[:], iconType.OSType)
+	renderOpts := png.DefaultRenderOptions()
+	renderOpts.Logger = opts.Logger
+	renderOpts.CompressionLevel = opts.CompressionLevel
+	renderOpts.PostProcess = opts.PostProcess
+	renderOpts.PerSize = opts.PerSize
+	renderOpts.BitDepth = opts.BitDepth
+	renderOpts.RejectBlank = opts.RejectBlank
+
+	// Generate png byte array for icon types
+	rendered := make(map[int][]byte)
+	for _, iconType := range types {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 
-		entry := IconEntry{
-			OSType: osTypeBytes,
-			Length: uint32(len(pngData) + 8), // Data size + 8 bytes for header (type and length)
-			Data:   pngData,
+		pngData, ok := rendered[iconType.Size]
+		if !ok {
+			var err error
+			pngData, err = png.RenderContext(ctx, func() ([]byte, error) {
+				return png.RenderIconCached(icon, opts.svgHash, iconType.Size, renderOpts, opts.CacheDir)
+			})
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				return nil, fmt.Errorf("rendering icns entry %s (%dpx): %w", iconType.OSType, iconType.Size, err)
+			}
+			rendered[iconType.Size] = pngData
+		}
+		entries = append(entries, newIconEntry(iconType.OSType, pngData))
+		if opts.Progress != nil {
+			opts.Progress(len(entries), len(types))
 		}
-		entries = append(entries, entry)
 	}
 
-	// Calculate the total file size.
-	// The total size starts with the 8-byte file header ('icns' + size).
-	totalSize := uint32(8)
+	return entries, nil
+}
+
+// newIconEntry creates an entry holding data under the given OSType code.
+// The length of data too large for the uint32 length field is rejected by
+// writeIcns.
+func newIconEntry(osType string, data []byte) IconEntry {
+	var osTypeBytes [4]byte
+	copy(osTypeBytes[:], osType)
+
+	return IconEntry{
+		OSType: osTypeBytes,
+		Length: uint32(len(data) + 8), // Data size + 8 bytes for header (type and length)
+		Data:   data,
+	}
+}
+
+// newTocEntry creates the 'TOC ' entry for the given entries. It lists the
+// OSType code and total length (including the 8 byte header) of each entry
+// in file order.
+func newTocEntry(entries []IconEntry) IconEntry {
+	var data []byte
 	for _, entry := range entries {
-		totalSize += entry.Length
+		data = append(data, entry.OSType[:]...)
+		data = binary.BigEndian.AppendUint32(data, entry.Length)
 	}
+	return newIconEntry("TOC ", data)
+}
 
-	// Generate the complete ICNS file in a buffer.
-	buffer := &bytes.Buffer{}
+// writeIcns writes the ICNS header followed by all entries to w.
+// Returns an error without writing anything if an entry or the whole file
+// is too large for the uint32 length fields, otherwise the first error
+// reported by the writer.
+func writeIcns(w io.Writer, entries []IconEntry) error {
+	// Calculate the total file size in 64 bits so an overflow can be detected.
+	// The total size starts with the 8-byte file header ('icns' + size).
+	totalSize := uint64(8)
+	for _, entry := range entries {
+		entryLength := uint64(len(entry.Data)) + 8
+		if entryLength > math.MaxUint32 {
+			return fmt.Errorf("icns entry %s of %d bytes exceeds the maximum entry length of %d bytes", entry.OSType[:], entryLength, uint64(math.MaxUint32))
+		}
+		totalSize += entryLength
+	}
+	if totalSize > math.MaxUint32 {
+		return fmt.Errorf("icns file of %d bytes exceeds the maximum file length of %d bytes", totalSize, uint64(math.MaxUint32))
+	}
 
 	// Write the main ICNS header.
-	buffer.WriteString("icns")
+	if _, err := io.WriteString(w, "icns"); err != nil {
+		return err
+	}
 	// Total file size, encoded in Big Endian byte order.
-	if err := binary.Write(buffer, binary.BigEndian, totalSize); err != nil {
+	if err := binary.Write(w, binary.BigEndian, uint32(totalSize)); err != nil {
 		return err
 	}
 
 	// Write all the icon entries.
 	for _, entry := range entries {
-		buffer.Write(entry.OSType[:])
-		if err := binary.Write(buffer, binary.BigEndian, entry.Length); err != nil {
+		if _, err := w.Write(entry.OSType[:]); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.BigEndian, entry.Length); err != nil {
+			return err
+		}
+		// Write the actual image data for the icon.
+		if _, err := w.Write(entry.Data); err != nil {
 			return err
 		}
-		// Write the actual PNG data for the icon.
-		buffer.Write(entry.Data)
 	}
 
-	// Write the buffer to the output file
-	err := os.WriteFile(outputPath, buffer.Bytes(), 0644)
-	if err != nil {
-		return err
-	}
+	return nil
+}
 
+// ValidateTypes checks that types is non-empty and that every type uses one
+// of the OSType codes in StandardIconTypes with the pixel dimensions the code
+// stands for, e.g. 512 for ic09. Each code stores exactly one size, so sizes
+// without a code of their own (such as 768) can't be written to an ICNS file
+// at all.
+//
+// Parameters:
+//   - types: Icon types of a custom ICNS layout
+//
+// Returns an error naming the first invalid type, or nil.
+func ValidateTypes(types []IconType) error {
+	if len(types) == 0 {
+		return errors.New("no ICNS icon types given")
+	}
+	for _, iconType := range types {
+		size, ok := osTypeSize(iconType.OSType)
+		if !ok {
+			return fmt.Errorf("invalid ICNS icon type %q: unknown OSType code", iconType.OSType)
+		}
+		if iconType.Size != size {
+			return fmt.Errorf("invalid ICNS icon type %q: size %d doesn't match its %dx%d dimensions", iconType.OSType, iconType.Size, size, size)
+		}
+	}
 	return nil
 }
+
+// osTypeSize returns the pixel size of the OSType code in StandardIconTypes.
+func osTypeSize(osType string) (int, bool) {
+	for _, iconType := range StandardIconTypes {
+		if iconType.OSType == osType {
+			return iconType.Size, true
+		}
+	}
+	return 0, false
+}
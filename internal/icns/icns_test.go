@@ -0,0 +1,240 @@
+package icns
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testSvg is a small icon with a gradient and transparent corners used as
+// input by the tests.
+const testSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">
+<defs><linearGradient id="g" x2="1"><stop offset="0" stop-color="#f60"/><stop offset="1" stop-color="#06f"/></linearGradient></defs>
+<circle cx="32" cy="32" r="28" fill="url(#g)"/>
+</svg>
+`
+
+// writeSvg writes svg into a temporary directory and returns its path.
+func writeSvg(t testing.TB, svg string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "icon.svg")
+	if err := os.WriteFile(path, []byte(svg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// entryData returns the data of every entry of an ICNS file by OSType,
+// failing the test if the file can't be read.
+func entryData(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	entries, err := ReadIcns(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byType := make(map[string][]byte)
+	for _, entry := range entries {
+		byType[string(entry.OSType[:])] = entry.Data
+	}
+	return byType
+}
+
+func TestEncodeIcnsRendersEachSizeOnce(t *testing.T) {
+	svgPath := writeSvg(t, testSvg)
+
+	// Count the renders of every size through the log
+	renders := make(map[string]int)
+	logger := png.LoggerFunc(func(format string, args ...any) {
+		if message := fmt.Sprintf(format, args...); strings.HasPrefix(message, "rendering ") {
+			renders[strings.TrimPrefix(message, "rendering ")]++
+		}
+	})
+	output := filepath.Join(t.TempDir(), "icon.icns")
+	if err := CreateIcnsOptions(svgPath, output, Options{Logger: logger}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := entryData(t, data)
+
+	// Every entry holds the bytes of rendering its size on its own
+	icon, err := png.ParseSvg(svgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, iconType := range StandardIconTypes {
+		want, err := png.RenderIcon(icon, iconType.Size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(entries[iconType.OSType], want) {
+			t.Errorf("%s differs from a %dpx render", iconType.OSType, iconType.Size)
+		}
+	}
+
+	// Types sharing a size share the bytes of a single render
+	for _, pair := range [][2]string{{"ic11", "icp5"}, {"ic12", "icp6"}, {"ic13", "ic08"}, {"ic14", "ic09"}} {
+		if !bytes.Equal(entries[pair[0]], entries[pair[1]]) {
+			t.Errorf("%s and %s differ", pair[0], pair[1])
+		}
+	}
+	sizes := make(map[int]bool)
+	for _, iconType := range StandardIconTypes {
+		sizes[iconType.Size] = true
+	}
+	if len(renders) != len(sizes) {
+		t.Errorf("rendered sizes %v, want the %d sizes of StandardIconTypes", renders, len(sizes))
+	}
+	for size, count := range renders {
+		if count != 1 {
+			t.Errorf("rendered %s %d times", size, count)
+		}
+	}
+}
+
+func TestValidateTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		types []IconType
+		want  string // Error message, empty if the types are valid
+	}{
+		{name: "standard types", types: StandardIconTypes},
+		{name: "subset", types: []IconType{{OSType: "icp4", Size: 16}, {OSType: "ic10", Size: 1024}}},
+		{name: "nil", types: nil, want: "no ICNS icon types given"},
+		{name: "empty", types: []IconType{}, want: "no ICNS icon types given"},
+		{name: "unknown code", types: []IconType{{OSType: "ic99", Size: 64}}, want: `invalid ICNS icon type "ic99": unknown OSType code`},
+		{name: "legacy code", types: []IconType{{OSType: "is32", Size: 16}}, want: `invalid ICNS icon type "is32": unknown OSType code`},
+		{name: "size of another code", types: []IconType{{OSType: "ic09", Size: 256}}, want: `invalid ICNS icon type "ic09": size 256 doesn't match its 512x512 dimensions`},
+		{name: "size without a code", types: []IconType{{OSType: "ic10", Size: 768}}, want: `invalid ICNS icon type "ic10": size 768 doesn't match its 1024x1024 dimensions`},
+		{name: "size out of range", types: []IconType{{OSType: "icp4", Size: 8}}, want: `invalid ICNS icon type "icp4": size 8 doesn't match its 16x16 dimensions`},
+		{name: "first invalid type", types: []IconType{{OSType: "icp4", Size: 16}, {OSType: "ic07", Size: 64}, {OSType: "xxxx", Size: 16}}, want: `invalid ICNS icon type "ic07": size 64 doesn't match its 128x128 dimensions`},
+	}
+	svgPath := writeSvg(t, testSvg)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateTypes(test.types)
+			switch {
+			case test.want == "" && err != nil:
+				t.Fatalf("got %v, want no error", err)
+			case test.want != "" && (err == nil || err.Error() != test.want):
+				t.Fatalf("got %v, want %q", err, test.want)
+			}
+
+			// The writers reject the same types before writing anything
+			output := filepath.Join(t.TempDir(), "icon.icns")
+			err = CreateIcnsTypes(svgPath, output, test.types)
+			if test.want == "" {
+				if err != nil {
+					t.Errorf("CreateIcnsTypes: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.want {
+				t.Errorf("CreateIcnsTypes: got %v, want %q", err, test.want)
+			}
+			if _, err := os.Stat(output); err == nil {
+				t.Error("CreateIcnsTypes wrote the file")
+			}
+		})
+	}
+}
+
+func TestWriteIcnsRejectsOversizedEntries(t *testing.T) {
+	if math.MaxInt <= math.MaxUint32 {
+		t.Skip("entries beyond the uint32 length fields don't fit in memory")
+	}
+	// The data is never touched, so the operating system doesn't have to
+	// back the allocation with memory
+	huge := make([]byte, math.MaxUint32-7)
+	hugeData := func(n int) []byte { return huge[:n] }
+	small := newIconEntry("icp4", []byte{1, 2, 3})
+
+	tests := []struct {
+		name    string
+		entries []IconEntry
+		want    string
+	}{
+		{
+			name:    "entry length",
+			entries: []IconEntry{small, {OSType: [4]byte{'i', 'c', '1', '0'}, Data: hugeData(math.MaxUint32 - 7)}},
+			want:    "icns entry ic10 of 4294967296 bytes exceeds the maximum entry length of 4294967295 bytes",
+		},
+		{
+			name: "file length",
+			entries: []IconEntry{
+				{OSType: [4]byte{'i', 'c', '0', '9'}, Data: hugeData(math.MaxUint32 / 2)},
+				{OSType: [4]byte{'i', 'c', '1', '0'}, Data: hugeData(math.MaxUint32 / 2)},
+			},
+			want: "icns file of 4294967318 bytes exceeds the maximum file length of 4294967295 bytes",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			err := writeIcns(&buffer, test.entries)
+			if err == nil || err.Error() != test.want {
+				t.Fatalf("got error %v, want %q", err, test.want)
+			}
+			if buffer.Len() != 0 {
+				t.Errorf("wrote %d bytes before failing", buffer.Len())
+			}
+		})
+	}
+
+	// The largest entry that fits is accepted, and fails only at the writer
+	err := writeIcns(failWriter{}, []IconEntry{{OSType: [4]byte{'i', 'c', '1', '0'}, Data: hugeData(math.MaxUint32 - 16)}})
+	if !errors.Is(err, errWriteFailed) {
+		t.Errorf("got %v, want the writer's error", err)
+	}
+}
+
+// errWriteFailed is returned by failWriter.
+var errWriteFailed = errors.New("write failed")
+
+// failWriter fails every write.
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) { return 0, errWriteFailed }
+
+func TestCreateIcnsDirectWrite(t *testing.T) {
+	svgPath := writeSvg(t, testSvg)
+	opts := Options{Types: []IconType{{OSType: "icp4", Size: 16}, {OSType: "ic11", Size: 32}}}
+
+	// Both ways of writing produce the same file and leave nothing else behind
+	var files [][]byte
+	for _, direct := range []bool{false, true} {
+		dir := t.TempDir()
+		output := filepath.Join(dir, "icon.icns")
+		if err := os.WriteFile(output, []byte("old contents"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		opts.DirectWrite = direct
+		if err := CreateIcnsOptions(svgPath, output, opts); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, data)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("direct %v: directory holds %d files, want 1", direct, len(entries))
+		}
+	}
+	if !bytes.Equal(files[0], files[1]) {
+		t.Error("DirectWrite changed the written file")
+	}
+}
@@ -0,0 +1,68 @@
+package icns
+
+import (
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/atomicfile"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"os"
+	"path/filepath"
+)
+
+// iconsetNames maps ICNS OSType codes to the file names used by Apple's
+// iconutil inside an .iconset directory. Types without a counterpart in the
+// iconset naming scheme (e.g. icp6) are not listed.
+var iconsetNames = map[string]string{
+	"icp4": "icon_16x16.png",
+	"ic11": "icon_16x16@2x.png",
+	"icp5": "icon_32x32.png",
+	"ic12": "icon_32x32@2x.png",
+	"ic07": "icon_128x128.png",
+	"ic13": "icon_128x128@2x.png",
+	"ic08": "icon_256x256.png",
+	"ic14": "icon_256x256@2x.png",
+	"ic09": "icon_512x512.png",
+	"ic10": "icon_512x512@2x.png",
+}
+
+// CreateIconset generates a macOS .iconset directory from an SVG source.
+//
+// The directory receives one PNG per icon type in StandardIconTypes, named
+// the way Apple's iconutil expects (icon_16x16.png, icon_16x16@2x.png, ...
+// icon_512x512@2x.png). Running `iconutil -c icns` on the result produces an
+// equivalent ICNS file. The directory is created if it doesn't exist.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - dirPath: Path of the .iconset directory to write into
+//
+// Returns an error if SVG processing, directory creation or file writing fails.
+func CreateIconset(svgPath string, dirPath string) error {
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return err
+	}
+
+	// Parse the SVG once and reuse it for every icon type
+	icon, err := png.ParseSvg(svgPath)
+	if err != nil {
+		return err
+	}
+
+	for _, iconType := range StandardIconTypes {
+		name, ok := iconsetNames[iconType.OSType]
+		if !ok {
+			continue
+		}
+
+		pngData, err := png.RenderIcon(icon, iconType.Size)
+		if err != nil {
+			return fmt.Errorf("rendering icns entry %s (%dpx): %w", iconType.OSType, iconType.Size, err)
+		}
+
+		err = atomicfile.WriteFile(filepath.Join(dirPath, name), pngData, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package icns
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/atomicfile"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"image"
+	"image/color"
+)
+
+// LegacyIconTypes defines the classic ICNS icon types read by Mac OS X 10.5-10.7,
+// which don't understand PNG-compressed entries. Every size consists of an
+// RLE-compressed 24-bit RGB entry and an uncompressed 8-bit alpha mask entry.
+var LegacyIconTypes = []LegacyIconType{
+	{ColorType: "is32", MaskType: "s8mk", Size: 16},  // 16x16
+	{ColorType: "il32", MaskType: "l8mk", Size: 32},  // 32x32
+	{ColorType: "ih32", MaskType: "h8mk", Size: 48},  // 48x48
+	{ColorType: "it32", MaskType: "t8mk", Size: 128}, // 128x128
+}
+
+// LegacyIconType represents a classic ICNS icon size with its color and mask OSType codes
+type LegacyIconType struct {
+	ColorType string
+	MaskType  string
+	Size      int
+}
+
+// CreateIcnsLegacy generates a macOS ICNS file that also works on older macOS
+// versions (10.5-10.7).
+//
+// In addition to the PNG entries of CreateIcns, the file contains the classic
+// RLE-compressed RGB entries and 8-bit masks listed in LegacyIconTypes.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - outputPath: Path where the ICNS file will be written
+//
+// Returns an error if SVG processing or file writing fails.
+func CreateIcnsLegacy(svgPath string, outputPath string) error {
+	// Parse the SVG once and reuse it for every icon type
+	icon, err := png.ParseSvg(svgPath)
+	if err != nil {
+		return err
+	}
+
+	var entries []IconEntry
+	for _, legacyType := range LegacyIconTypes {
+		canvas, err := png.RasterizeIcon(icon, legacyType.Size, png.DefaultRenderOptions())
+		if err != nil {
+			return fmt.Errorf("rendering icns entry %s (%dpx): %w", legacyType.ColorType, legacyType.Size, err)
+		}
+
+		colorData, maskData := encodeLegacy(canvas)
+		// it32 data starts with four zero bytes
+		if legacyType.ColorType == "it32" {
+			colorData = append([]byte{0, 0, 0, 0}, colorData...)
+		}
+
+		entries = append(entries,
+			newIconEntry(legacyType.ColorType, colorData),
+			newIconEntry(legacyType.MaskType, maskData),
+		)
+	}
+
+	pngEntries, err := renderEntries(context.Background(), icon, StandardIconTypes, Options{})
+	if err != nil {
+		return err
+	}
+	entries = append(entries, pngEntries...)
+
+	buffer := &bytes.Buffer{}
+	if err := writeIcns(buffer, entries); err != nil {
+		return err
+	}
+
+	// Write the buffer to the output file
+	err = atomicfile.WriteFile(outputPath, buffer.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// encodeLegacy splits an image into the classic ICNS color and mask data.
+// The color data holds the RLE-compressed red, green and blue channels one
+// after another, the mask data holds the uncompressed alpha channel.
+func encodeLegacy(img *image.RGBA) ([]byte, []byte) {
+	bounds := img.Bounds()
+	pixels := bounds.Dx() * bounds.Dy()
+	red := make([]byte, 0, pixels)
+	green := make([]byte, 0, pixels)
+	blue := make([]byte, 0, pixels)
+	mask := make([]byte, 0, pixels)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			red = append(red, pixel.R)
+			green = append(green, pixel.G)
+			blue = append(blue, pixel.B)
+			mask = append(mask, pixel.A)
+		}
+	}
+
+	var colorData []byte
+	for _, channel := range [][]byte{red, green, blue} {
+		colorData = append(colorData, compressRLE(channel)...)
+	}
+	return colorData, mask
+}
+
+// compressRLE compresses a single channel with the ICNS run-length encoding.
+//
+// A control byte below 0x80 is followed by control+1 literal bytes (1-128).
+// A control byte of 0x80 or above repeats the following byte control-0x80+3
+// times (3-130).
+func compressRLE(data []byte) []byte {
+	var out []byte
+	var literal []byte
+
+	flushLiteral := func() {
+		for len(literal) > 0 {
+			n := min(len(literal), 128)
+			out = append(out, byte(n-1))
+			out = append(out, literal[:n]...)
+			literal = literal[n:]
+		}
+	}
+
+	for i := 0; i < len(data); {
+		// Measure the run of identical bytes starting at i
+		run := 1
+		for i+run < len(data) && run < 130 && data[i+run] == data[i] {
+			run++
+		}
+
+		if run >= 3 {
+			flushLiteral()
+			out = append(out, byte(0x80+run-3), data[i])
+		} else {
+			literal = append(literal, data[i:i+run]...)
+		}
+		i += run
+	}
+	flushLiteral()
+
+	return out
+}
@@ -0,0 +1,194 @@
+package icns
+
+import (
+	"bytes"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"image"
+	"image/color"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// redSvg fills its whole viewBox with opaque red.
+const redSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 8 8"><rect width="8" height="8" fill="#f00"/></svg>`
+
+// redIs32 and redS8mk are the is32 and s8mk entries of a 16x16 opaque red
+// icon. Every channel of 256 bytes is a run of 130 and a run of 126.
+var (
+	redIs32 = []byte{
+		0xff, 0xff, 0xfb, 0xff, // Red
+		0xff, 0x00, 0xfb, 0x00, // Green
+		0xff, 0x00, 0xfb, 0x00, // Blue
+	}
+	redS8mk = bytes.Repeat([]byte{0xff}, 16*16)
+)
+
+// sequence returns the bytes 0, 1, ... n-1 (modulo 256).
+func sequence(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	return data
+}
+
+func TestCompressRLE(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"empty", nil, nil},
+		{"single byte", []byte{7}, []byte{0x00, 7}},
+		{"short run stays literal", []byte{7, 7}, []byte{0x01, 7, 7}},
+		{"shortest run", []byte{7, 7, 7}, []byte{0x80, 7}},
+		{"longest run", bytes.Repeat([]byte{7}, 130), []byte{0xff, 7}},
+		{"run split after 130", bytes.Repeat([]byte{7}, 131), []byte{0xff, 7, 0x00, 7}},
+		{"run split into runs", bytes.Repeat([]byte{7}, 133), []byte{0xff, 7, 0x80, 7}},
+		{"literal then run", []byte{1, 2, 3, 3, 3, 3, 4}, []byte{0x01, 1, 2, 0x81, 3, 0x00, 4}},
+		{"longest literal", sequence(128), append([]byte{0x7f}, sequence(128)...)},
+		{"literal split after 128", sequence(129), append(append([]byte{0x7f}, sequence(128)...), 0x00, 128)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := compressRLE(test.data)
+			if !bytes.Equal(got, test.want) {
+				t.Errorf("compressRLE = % x, want % x", got, test.want)
+			}
+
+			decoded, err := decompressRLE(got, len(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, test.data) {
+				t.Errorf("round trip gave % x", decoded)
+			}
+		})
+	}
+}
+
+func TestRLERoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := range 200 {
+		// Few distinct values give a mix of runs and literals
+		data := make([]byte, random.Intn(2000)+1)
+		for j := range data {
+			data[j] = byte(random.Intn(1 + i%4))
+		}
+
+		decoded, err := decompressRLE(compressRLE(data), len(data))
+		if err != nil {
+			t.Fatalf("%d bytes: %v", len(data), err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatalf("%d bytes: round trip differs", len(data))
+		}
+	}
+}
+
+func TestDecompressRLEErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		size int
+	}{
+		{"truncated literal", []byte{0x03, 1, 2}, 4},
+		{"truncated run", []byte{0x80}, 3},
+		{"too short", []byte{0x80, 1}, 4},
+		{"too long", []byte{0x81, 1}, 3},
+	}
+	for _, test := range tests {
+		if _, err := decompressRLE(test.data, test.size); err == nil {
+			t.Errorf("%s: decoded without an error", test.name)
+		}
+	}
+}
+
+func TestEncodeLegacy(t *testing.T) {
+	red := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := 0; i < len(red.Pix); i += 4 {
+		copy(red.Pix[i:], []byte{0xff, 0, 0, 0xff})
+	}
+	colorData, mask := encodeLegacy(red)
+	if !bytes.Equal(colorData, redIs32) {
+		t.Errorf("color data % x, want % x", colorData, redIs32)
+	}
+	if !bytes.Equal(mask, redS8mk) {
+		t.Errorf("mask % x, want % x", mask, redS8mk)
+	}
+
+	// Straight colors and alpha survive the round trip through the decoder
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := range 16 {
+		for x := range 16 {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 16), G: uint8(y * 16), B: 0x80, A: uint8(255 - x*y)})
+		}
+	}
+	colorData, mask = encodeLegacy(img)
+	decoded, err := decodeLegacy(LegacyIconTypes[0], colorData, mask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := range 16 {
+		for x := range 16 {
+			want := color.NRGBAModel.Convert(img.At(x, y))
+			if got := decoded.NRGBAAt(x, y); got != want {
+				t.Fatalf("pixel %d,%d decodes to %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestCreateIcnsLegacy(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "icon.icns")
+	if err := CreateIcnsLegacy(writeSvg(t, redSvg), output); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := entryData(t, data)
+
+	if !bytes.Equal(entries["is32"], redIs32) {
+		t.Errorf("is32 is % x, want % x", entries["is32"], redIs32)
+	}
+	if !bytes.Equal(entries["s8mk"], redS8mk) {
+		t.Errorf("s8mk is % x, want % x", entries["s8mk"], redS8mk)
+	}
+	if it32 := entries["it32"]; !bytes.HasPrefix(it32, []byte{0, 0, 0, 0}) {
+		t.Errorf("it32 starts with % x, want four zero bytes", it32[:min(len(it32), 4)])
+	}
+
+	// Every legacy size decodes to the rendered image
+	icon, err := png.ParseSvgReader(bytes.NewReader([]byte(testSvg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output = filepath.Join(t.TempDir(), "gradient.icns")
+	if err := CreateIcnsLegacy(writeSvg(t, testSvg), output); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = os.ReadFile(output); err != nil {
+		t.Fatal(err)
+	}
+	entries = entryData(t, data)
+	for _, legacyType := range LegacyIconTypes {
+		decoded, err := decodeLegacy(legacyType, entries[legacyType.ColorType], entries[legacyType.MaskType])
+		if err != nil {
+			t.Fatalf("%s: %v", legacyType.ColorType, err)
+		}
+		want, err := png.RasterizeIcon(icon, legacyType.Size, png.DefaultRenderOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range legacyType.Size * legacyType.Size {
+			x, y := i%legacyType.Size, i/legacyType.Size
+			if got, want := decoded.NRGBAAt(x, y), color.NRGBAModel.Convert(want.At(x, y)); got != want {
+				t.Fatalf("%s: pixel %d,%d decodes to %v, want %v", legacyType.ColorType, x, y, got, want)
+			}
+		}
+	}
+}
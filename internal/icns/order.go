@@ -0,0 +1,38 @@
+package icns
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Order selects the order in which the icon entries are written. Some ICNS
+// readers use the first entry of a matching size they find, so the order
+// can decide which resolution a thumbnail is generated from.
+type Order int
+
+// The entry orders accepted by Options.Order.
+const (
+	// OrderAsListed writes the entries in the order of the icon types,
+	// StandardIconTypes unless Options.Types is set
+	OrderAsListed Order = iota
+	// OrderLargestFirst writes the largest entries first
+	OrderLargestFirst
+	// OrderSmallestFirst writes the smallest entries first
+	OrderSmallestFirst
+)
+
+// apply returns types sorted by the order. Types of the same size keep their
+// listed order.
+func (o Order) apply(types []IconType) []IconType {
+	switch o {
+	case OrderLargestFirst:
+		return slices.SortedStableFunc(slices.Values(types), func(a, b IconType) int {
+			return cmp.Compare(b.Size, a.Size)
+		})
+	case OrderSmallestFirst:
+		return slices.SortedStableFunc(slices.Values(types), func(a, b IconType) int {
+			return cmp.Compare(a.Size, b.Size)
+		})
+	}
+	return types
+}
@@ -0,0 +1,60 @@
+package icns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotIcns is returned when the input doesn't start with the 'icns' magic.
+var ErrNotIcns = errors.New("input is not an ICNS file")
+
+// ReadIcns parses an ICNS file and returns its icon entries.
+//
+// The total size in the file header must match the data read from r, and
+// every entry must lie within it. A 'TOC ' table of contents only repeats
+// what the entries already describe, so it is skipped.
+//
+// Parameters:
+//   - r: Reader providing the complete ICNS file
+//
+// Returns the entries in file order, ErrNotIcns if the magic is missing, or an
+// error if the file is truncated or malformed.
+func ReadIcns(r io.Reader) ([]IconEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 || string(data[:4]) != "icns" {
+		return nil, ErrNotIcns
+	}
+
+	totalSize := binary.BigEndian.Uint32(data[4:8])
+	if int64(totalSize) != int64(len(data)) {
+		return nil, fmt.Errorf("ICNS header declares %d bytes, but the file has %d", totalSize, len(data))
+	}
+
+	var entries []IconEntry
+	for offset := 8; offset < len(data); {
+		if len(data)-offset < 8 {
+			return nil, fmt.Errorf("truncated ICNS entry header at offset %d", offset)
+		}
+
+		var entry IconEntry
+		copy(entry.OSType[:], data[offset:offset+4])
+		entry.Length = binary.BigEndian.Uint32(data[offset+4 : offset+8])
+		if entry.Length < 8 || int64(entry.Length) > int64(len(data)-offset) {
+			return nil, fmt.Errorf("invalid length %d of ICNS entry %q at offset %d", entry.Length, entry.OSType[:], offset)
+		}
+		entry.Data = data[offset+8 : offset+int(entry.Length)]
+		offset += int(entry.Length)
+
+		if string(entry.OSType[:]) == "TOC " {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,111 @@
+package icns
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestReadIcnsRoundTrip(t *testing.T) {
+	entries := []IconEntry{
+		newIconEntry("icp4", []byte("first image")),
+		newIconEntry("ic10", bytes.Repeat([]byte{0xab}, 1000)),
+		newIconEntry("s8mk", nil),
+	}
+
+	tests := []struct {
+		name string
+		toc  bool
+	}{
+		{"without TOC", false},
+		{"with TOC", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fileEntries := entries
+			if test.toc {
+				fileEntries = append([]IconEntry{newTocEntry(entries)}, entries...)
+			}
+			var buffer bytes.Buffer
+			if err := writeIcns(&buffer, fileEntries); err != nil {
+				t.Fatal(err)
+			}
+			if test.toc && string(buffer.Bytes()[8:12]) != "TOC " {
+				t.Fatalf("file starts with entry %q, want the TOC", buffer.Bytes()[8:12])
+			}
+
+			// The TOC is skipped, the other entries come back unchanged
+			got, err := ReadIcns(&buffer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(entries) {
+				t.Fatalf("read %d entries, want %d", len(got), len(entries))
+			}
+			for i, entry := range got {
+				want := entries[i]
+				if entry.OSType != want.OSType || entry.Length != want.Length || !bytes.Equal(entry.Data, want.Data) {
+					t.Errorf("entry %d is %q (%d bytes), want %q (%d bytes)", i, entry.OSType[:], entry.Length, want.OSType[:], want.Length)
+				}
+			}
+		})
+	}
+}
+
+func TestReadIcnsEncodedFile(t *testing.T) {
+	data, err := EncodeIcnsReaderOptions(bytes.NewReader([]byte(testSvg)), Options{TOC: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ReadIcns(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(StandardIconTypes) {
+		t.Fatalf("read %d entries, want %d", len(entries), len(StandardIconTypes))
+	}
+	for i, entry := range entries {
+		if osType := string(entry.OSType[:]); osType != StandardIconTypes[i].OSType {
+			t.Errorf("entry %d is %q, want %q", i, osType, StandardIconTypes[i].OSType)
+		}
+		if !bytes.HasPrefix(entry.Data, pngSignature) {
+			t.Errorf("entry %q doesn't hold a PNG", entry.OSType[:])
+		}
+	}
+}
+
+func TestReadIcnsErrors(t *testing.T) {
+	// header returns an ICNS header declaring size bytes
+	header := func(size uint32) []byte {
+		return binary.BigEndian.AppendUint32([]byte("icns"), size)
+	}
+	entry := func(osType string, length uint32, data string) []byte {
+		return append(binary.BigEndian.AppendUint32([]byte(osType), length), data...)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		notIcns bool
+	}{
+		{name: "empty", data: nil, notIcns: true},
+		{name: "wrong magic", data: append([]byte("icon"), 0, 0, 0, 8), notIcns: true},
+		{name: "size larger than the file", data: header(9)},
+		{name: "size smaller than the file", data: append(header(8), entry("icp4", 9, "x")...)},
+		{name: "truncated entry header", data: append(header(12), "icp4"...)},
+		{name: "entry length below 8", data: append(header(16), entry("icp4", 7, "")...)},
+		{name: "entry past the end", data: append(header(17), entry("icp4", 10, "x")...)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadIcns(bytes.NewReader(test.data))
+			if err == nil {
+				t.Fatal("read without an error")
+			}
+			if errors.Is(err, ErrNotIcns) != test.notIcns {
+				t.Errorf("got %v, ErrNotIcns %v", err, test.notIcns)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package icns
+
+import (
+	"context"
+	"github.com/julian-bruyers/svg2icon/internal/atomicfile"
+)
+
+// IcnsResult describes the contents of a generated ICNS file.
+type IcnsResult struct {
+	OSTypes    []string // OSType codes of the entries, in file order
+	EntryBytes []int    // Image data size in bytes of each entry, excluding the 8 byte entry header
+	TotalBytes int      // Size of the complete ICNS file in bytes
+}
+
+// CreateIcnsResult generates a macOS ICNS file like CreateIcns and reports
+// what was written.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - outputPath: Path where the ICNS file will be written
+//
+// Returns the description of the written file, or an error if SVG processing
+// or file writing fails.
+func CreateIcnsResult(svgPath string, outputPath string) (IcnsResult, error) {
+	data, result, err := encodeIcns(context.Background(), svgPath, StandardIconTypes, Options{})
+	if err != nil {
+		return IcnsResult{}, err
+	}
+
+	// Write the encoded icon to the output file
+	err = atomicfile.WriteFile(outputPath, data, 0644)
+	if err != nil {
+		return IcnsResult{}, err
+	}
+
+	return result, nil
+}
+
+// newIcnsResult builds the result for the given entries.
+func newIcnsResult(entries []IconEntry, totalBytes int) IcnsResult {
+	result := IcnsResult{TotalBytes: totalBytes}
+	for _, entry := range entries {
+		result.OSTypes = append(result.OSTypes, string(entry.OSType[:]))
+		result.EntryBytes = append(result.EntryBytes, len(entry.Data))
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package ico
+
+import (
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchSvg has enough paths and gradients for parsing to show up next to
+// rendering.
+const benchSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">
+<defs><linearGradient id="g" x2="1" y2="1"><stop offset="0" stop-color="#f60"/><stop offset="1" stop-color="#06f"/></linearGradient></defs>
+<rect width="64" height="64" fill="url(#g)"/>
+<circle cx="32" cy="32" r="24" fill="none" stroke="#fff" stroke-width="3"/>
+<path d="M20 40 C20 20 44 20 44 40 S28 56 20 40 Z" fill="#fff" fill-opacity="0.6"/>
+<path d="M12 12 L52 12 L52 52 L12 52 Z M16 16 L48 16 L48 48 L16 48 Z" fill="#123" fill-rule="evenodd"/>
+</svg>
+`
+
+// BenchmarkCreateIco compares rendering the IconSizes of an ICO from an SVG
+// that is parsed once, as CreateIco does, against parsing it again for every
+// size.
+func BenchmarkCreateIco(b *testing.B) {
+	svgPath := filepath.Join(b.TempDir(), "icon.svg")
+	if err := os.WriteFile(svgPath, []byte(benchSvg), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("ParseOnce", func(b *testing.B) {
+		for b.Loop() {
+			icon, err := png.ParseSvg(svgPath)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, size := range IconSizes {
+				if _, err := png.RenderIcon(icon, size); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("ParsePerSize", func(b *testing.B) {
+		for b.Loop() {
+			for _, size := range IconSizes {
+				if _, err := png.SvgToPng(svgPath, size); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package ico
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/atomicfile"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"slices"
+)
+
+// budgetKeptSizes are never dropped by CreateIcoWithinBudget, since browsers
+// and Windows fall back to them when no better size is available.
+var budgetKeptSizes = []int{16, 32}
+
+// CreateIcoWithinBudget generates a Windows ICO file like CreateIco that is
+// at most maxBytes large.
+//
+// All standard sizes are rendered first. While the file is too large, the
+// entry with the most encoded bytes is dropped (typically 256x256, then
+// 128x128). The 16x16 and 32x32 entries are always kept.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - outputPath: Path where the ICO file will be written
+//   - maxBytes: Maximum size of the ICO file in bytes
+//
+// Returns the dropped sizes in the order they were dropped, or an error if
+// even the 16x16 and 32x32 entries exceed the budget or SVG processing or
+// file writing fails.
+func CreateIcoWithinBudget(svgPath string, outputPath string, maxBytes int) ([]int, error) {
+	// Parse the SVG once and reuse it for every size
+	icon, err := png.ParseSvg(svgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := slices.Clone(IconSizes)
+	imageData, err := renderSizes(context.Background(), icon, sizes, 1, Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []int
+	for icoFileSize(imageData) > maxBytes {
+		largest := -1
+		for i, size := range sizes {
+			if slices.Contains(budgetKeptSizes, size) {
+				continue
+			}
+			if largest < 0 || len(imageData[i]) > len(imageData[largest]) {
+				largest = i
+			}
+		}
+		if largest < 0 {
+			return nil, fmt.Errorf("ICO of %d bytes with sizes %v exceeds the budget of %d bytes", icoFileSize(imageData), sizes, maxBytes)
+		}
+
+		dropped = append(dropped, sizes[largest])
+		sizes = slices.Delete(sizes, largest, largest+1)
+		imageData = slices.Delete(imageData, largest, largest+1)
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := writeIco(buffer, sizes, imageData); err != nil {
+		return nil, err
+	}
+
+	// Write the encoded icon to the output file
+	err = atomicfile.WriteFile(outputPath, buffer.Bytes(), 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return dropped, nil
+}
+
+// icoFileSize returns the size of an ICO file holding the given images:
+// the 6 byte header, a 16 byte directory entry per image and the image data.
+func icoFileSize(imageData [][]byte) int {
+	total := 6 + 16*len(imageData)
+	for _, data := range imageData {
+		total += len(data)
+	}
+	return total
+}
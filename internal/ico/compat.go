@@ -0,0 +1,144 @@
+package ico
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"github.com/srwiley/oksvg"
+	"image"
+	"image/color"
+)
+
+// CompatBmpMaxSize is the largest icon size CreateIcoCompat stores as an
+// uncompressed BMP entry. Larger sizes stay PNG-compressed.
+const CompatBmpMaxSize = 48
+
+// BITMAPINFOHEADER is the DIB header preceding the pixel data of a BMP entry
+type BITMAPINFOHEADER struct {
+	Size          uint32 // Header size (40 bytes)
+	Width         int32  // Width in pixels
+	Height        int32  // Height in pixels, doubled to cover the XOR and AND bitmaps
+	Planes        uint16 // Color planes (must be 1)
+	BitCount      uint16 // Bits per pixel
+	Compression   uint32 // Compression type (0 = BI_RGB, uncompressed)
+	SizeImage     uint32 // Size of the XOR and AND bitmaps
+	XPelsPerMeter int32  // Horizontal resolution (unused, 0)
+	YPelsPerMeter int32  // Vertical resolution (unused, 0)
+	ClrUsed       uint32 // Palette colors used (0 for 32bpp)
+	ClrImportant  uint32 // Important palette colors (0 for 32bpp)
+}
+
+// CreateIcoCompat generates a Windows ICO file for maximum compatibility with
+// legacy software.
+//
+// PNG-compressed entries were introduced with Windows Vista. Windows XP and
+// earlier, the resource compilers and icon editors of Visual Studio 2005 and
+// older, VB6/Delphi 7 era IDEs and some legacy installer builders only read
+// classic DIB entries and show a blank or broken icon otherwise.
+// CreateIcoCompat therefore stores all sizes up to CompatBmpMaxSize as 32-bit
+// BMP entries (BGRA pixels followed by a 1-bit AND transparency mask), while
+// the larger sizes stay PNG-compressed to keep the file small.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - outputPath: Path where the ICO file will be written
+//
+// Returns an error if SVG processing or file writing fails.
+func CreateIcoCompat(svgPath string, outputPath string) error {
+	return CreateIcoCompatOptions(svgPath, outputPath, IconSizes, Options{})
+}
+
+// CreateIcoCompatOptions generates a Windows ICO file with BMP entries like
+// CreateIcoCompat, containing the given sizes and using the given options
+// like CreateIcoOptions. BitDepth and CacheDir only apply to the PNG entries,
+// BMP entries always hold 32-bit pixels and are rendered every time.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - outputPath: Path where the ICO file will be written
+//   - sizes: Icon sizes in pixels, each between 1 and 256
+//   - opts: Options controlling the generation
+//
+// Returns an error if a size is out of range or SVG processing or file writing fails.
+func CreateIcoCompatOptions(svgPath string, outputPath string, sizes []int, opts Options) error {
+	return CreateIcoCompatOptionsContext(context.Background(), svgPath, outputPath, sizes, opts)
+}
+
+// CreateIcoCompatOptionsContext generates a Windows ICO file like
+// CreateIcoCompatOptions but stops as soon as ctx is cancelled, like
+// CreateIcoOptionsContext.
+//
+// Returns the context error if ctx is cancelled, or an error if a size is out
+// of range or SVG processing or file writing fails.
+func CreateIcoCompatOptionsContext(ctx context.Context, svgPath string, outputPath string, sizes []int, opts Options) error {
+	opts.bmpMaxSize = CompatBmpMaxSize
+	return CreateIcoOptionsContext(ctx, svgPath, outputPath, sizes, opts)
+}
+
+// renderDib rasterizes the icon at size and encodes it as a BMP entry,
+// running the PostProcess of renderOpts on the canvas first.
+func renderDib(icon *oksvg.SvgIcon, size int, renderOpts png.RenderOptions) ([]byte, error) {
+	canvas, err := png.RasterizeIcon(icon, size, renderOpts)
+	if err != nil {
+		return nil, err
+	}
+	postProcess := renderOpts.PostProcess
+	if override, ok := renderOpts.PerSize[size]; ok && override.PostProcess != nil {
+		postProcess = override.PostProcess
+	}
+	if postProcess != nil {
+		if err := postProcess(size, canvas); err != nil {
+			return nil, fmt.Errorf("post-processing %dx%d: %w", size, size, err)
+		}
+	}
+	return encodeDib(canvas)
+}
+
+// encodeDib encodes an image as a 32-bit ICO DIB entry.
+//
+// ICO entries store the header height doubled because the 32bpp XOR bitmap is
+// followed by a 1bpp AND mask of the same dimensions. Both bitmaps are stored
+// bottom-up, and every mask row is padded to a multiple of 4 bytes.
+func encodeDib(img *image.RGBA) ([]byte, error) {
+	width := img.Bounds().Dx()
+	height := img.Bounds().Dy()
+	maskStride := (width + 31) / 32 * 4
+	xorSize := width * height * 4
+	andSize := maskStride * height
+
+	header := BITMAPINFOHEADER{
+		Size:      40,
+		Width:     int32(width),
+		Height:    int32(height * 2),
+		Planes:    1,
+		BitCount:  32,
+		SizeImage: uint32(xorSize + andSize),
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := binary.Write(buffer, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+
+	// XOR bitmap with straight (non-premultiplied) BGRA pixels, bottom row first
+	mask := make([]byte, andSize)
+	for row := 0; row < height; row++ {
+		y := img.Bounds().Min.Y + height - 1 - row
+		for x := 0; x < width; x++ {
+			pixel := color.NRGBAModel.Convert(img.At(img.Bounds().Min.X+x, y)).(color.NRGBA)
+			buffer.Write([]byte{pixel.B, pixel.G, pixel.R, pixel.A})
+
+			// Fully transparent pixels are masked out for readers ignoring alpha
+			if pixel.A == 0 {
+				mask[row*maskStride+x/8] |= 0x80 >> (x % 8)
+			}
+		}
+	}
+
+	// AND mask
+	buffer.Write(mask)
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,101 @@
+package ico
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// circleSvg leaves the corners of every size transparent.
+const circleSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">
+<circle cx="32" cy="32" r="28" fill="#2b6cb0"/>
+</svg>`
+
+func TestCreateIcoCompat(t *testing.T) {
+	svgPath := writeSvg(t, circleSvg)
+	output := filepath.Join(t.TempDir(), "icon.ico")
+
+	progress := 0
+	opts := Options{Progress: func(done, total int) { progress++ }}
+	if err := CreateIcoCompatOptions(svgPath, output, IconSizes, opts); err != nil {
+		t.Fatal(err)
+	}
+	if progress != len(IconSizes) {
+		t.Errorf("Progress called %d times, want %d", progress, len(IconSizes))
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ReadIco(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(IconSizes) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(IconSizes))
+	}
+
+	for i, entry := range entries {
+		size := IconSizes[i]
+		image := data[entry.ImageOffset : entry.ImageOffset+entry.BytesInRes]
+		if size > CompatBmpMaxSize {
+			if !bytes.HasPrefix(image, pngSignature) {
+				t.Errorf("%dpx entry isn't a PNG", size)
+			}
+			continue
+		}
+
+		var header BITMAPINFOHEADER
+		if err := binary.Read(bytes.NewReader(image), binary.LittleEndian, &header); err != nil {
+			t.Fatal(err)
+		}
+		// The height covers the XOR bitmap and the AND mask below it
+		maskStride := (size + 31) / 32 * 4
+		want := BITMAPINFOHEADER{
+			Size:      40,
+			Width:     int32(size),
+			Height:    int32(2 * size),
+			Planes:    1,
+			BitCount:  32,
+			SizeImage: uint32(size*size*4 + maskStride*size),
+		}
+		if header != want {
+			t.Errorf("%dpx header %+v, want %+v", size, header, want)
+		}
+		if entry.BitCount != 32 || entry.BytesInRes != 40+want.SizeImage {
+			t.Errorf("%dpx directory entry has BitCount %d and BytesInRes %d, want 32 and %d", size, entry.BitCount, entry.BytesInRes, 40+want.SizeImage)
+		}
+
+		// The corners are masked out, the center isn't. Rows are
+		// stored bottom-up, so the top row comes last.
+		mask := image[40+size*size*4:]
+		topRow := mask[(size-1)*maskStride:]
+		if topRow[0]&0x80 == 0 {
+			t.Errorf("%dpx: the transparent top-left pixel isn't masked", size)
+		}
+		center := mask[(size/2)*maskStride:]
+		if center[size/2/8]&(0x80>>(size/2%8)) != 0 {
+			t.Errorf("%dpx: the opaque center pixel is masked", size)
+		}
+	}
+}
+
+func TestCreateIcoCompatContext(t *testing.T) {
+	svgPath := writeSvg(t, testSvg)
+	output := filepath.Join(t.TempDir(), "icon.ico")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := CreateIcoCompatOptionsContext(ctx, svgPath, output, IconSizes, Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(output); err == nil {
+		t.Error("a cancelled conversion wrote the icon")
+	}
+}
@@ -0,0 +1,58 @@
+package ico
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/atomicfile"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+)
+
+// CreateCursor generates a Windows CUR cursor file from an SVG source.
+//
+// A cursor shares the ICO layout but marks the file as type 2 and stores the
+// hotspot, the pixel that is the actual click point, in place of the Planes
+// and BitCount fields of every directory entry.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - outputPath: Path where the CUR file will be written
+//   - hotspotX, hotspotY: Click point in pixels from the top-left corner,
+//     used for every entry and thus within the smallest size
+//   - sizes: Cursor sizes in pixels, each between 1 and 256
+//
+// Returns an error if a size is out of range, the hotspot lies outside an
+// entry, or SVG processing or file writing fails.
+func CreateCursor(svgPath string, outputPath string, hotspotX, hotspotY uint16, sizes []int) error {
+	if err := ValidateSizes(sizes); err != nil {
+		return err
+	}
+	sizes = NormalizeSizes(sizes)
+	if smallest := sizes[0]; int(hotspotX) >= smallest || int(hotspotY) >= smallest {
+		return fmt.Errorf("cursor hotspot (%d, %d) lies outside the %dx%d entry", hotspotX, hotspotY, smallest, smallest)
+	}
+
+	// Parse the SVG once and reuse it for every size
+	icon, err := png.ParseSvg(svgPath)
+	if err != nil {
+		return err
+	}
+
+	imageData, err := renderSizes(context.Background(), icon, sizes, 1, Options{})
+	if err != nil {
+		return err
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := writeIconDir(buffer, typeCursor, sizes, imageData, hotspotX, hotspotY); err != nil {
+		return err
+	}
+
+	// Write the encoded cursor to the output file
+	err = atomicfile.WriteFile(outputPath, buffer.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package ico
+
+import (
+	"encoding/base64"
+)
+
+// MediaType is the registered media type of ICO files.
+const MediaType = "image/vnd.microsoft.icon"
+
+// IcoDataURI generates a Windows ICO file from an SVG source and returns it as
+// a base64 data: URI, ready to be embedded in HTML or CSS.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - sizes: Icon sizes in pixels, each between 1 and 256
+//
+// Returns the data URI, or an error if a size is out of range or SVG
+// processing fails.
+func IcoDataURI(svgPath string, sizes []int) (string, error) {
+	data, err := EncodeIco(svgPath, sizes)
+	if err != nil {
+		return "", err
+	}
+	return DataURI(data), nil
+}
+
+// DataURI returns encoded ICO data as a base64 data: URI.
+func DataURI(data []byte) string {
+	return "data:" + MediaType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
@@ -6,14 +6,91 @@ package ico
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/atomicfile"
 	"github.com/julian-bruyers/svg2icon/internal/png"
-	"os"
+	"github.com/srwiley/oksvg"
+	"image"
+	"io"
+	"slices"
 )
 
 // The sizes used in Windows for .ico files
 var IconSizes []int = []int{16, 24, 32, 48, 64, 128, 256}
 
+// The range of sizes an ICO directory entry can represent
+const (
+	MinIconSize = 1
+	MaxIconSize = 256
+)
+
+// Options controls how an ICO file is generated.
+// The zero value produces the same file as CreateIcoSizes.
+type Options struct {
+	// Logger receives a message when each size starts and finishes rendering.
+	// A nil Logger disables logging.
+	Logger png.Logger
+
+	// CompressionLevel sets how hard the PNG images are compressed.
+	// The zero value is png.DefaultCompression.
+	CompressionLevel png.CompressionLevel
+
+	// Progress is called after each size has been rendered with the number of
+	// rendered sizes so far and the total number of sizes. Calls never overlap,
+	// even when sizes are rendered concurrently. A nil Progress is skipped.
+	Progress func(done, total int)
+
+	// CacheDir enables an on-disk cache of rendered images in the given
+	// directory (see png.DefaultCacheDir). Entries are keyed by a hash of the
+	// SVG markup, the size and the render options. Icons passed in already
+	// parsed are never cached. An empty CacheDir disables caching.
+	CacheDir string
+
+	// Strict fails the conversion with png.ErrUnsupportedFeature when the SVG
+	// uses features the rasterizer ignores (see png.DetectFeatures). Without
+	// it they are reported to the Logger and the conversion proceeds.
+	// Icons passed in already parsed are not checked.
+	Strict bool
+
+	// SkipFeatureCheck leaves out the check of Strict and the messages about
+	// unsupported features to the Logger, for callers that already checked
+	// the markup with png.CheckFeatures themselves. The Logger still
+	// receives the render messages.
+	SkipFeatureCheck bool
+
+	// RejectBlank makes the conversion fail with png.ErrBlankRender when an
+	// entry renders fully transparent, see png.RenderOptions.RejectBlank.
+	RejectBlank bool
+
+	// PostProcess is called with the canvas of every rendered size before it
+	// is encoded and may modify it in place, see
+	// png.RenderOptions.PostProcess. It is called concurrently when sizes are
+	// rendered in parallel. An error aborts the conversion.
+	PostProcess func(size int, img *image.RGBA) error
+
+	// DirectWrite streams the file straight to the output path, see
+	// atomicfile.Write. By default it is written to a temporary file in the
+	// same directory and renamed into place once complete, so readers never
+	// see a partial icon.
+	DirectWrite bool
+
+	// PerSize overrides the render options for specific pixel sizes, e.g.
+	// to supersample only the small sizes, see png.RenderOptions.PerSize.
+	// Sizes without an entry are rendered with png.DefaultRenderOptions.
+	PerSize map[int]png.RenderOptions
+
+	// BitDepth sets the bits per channel of the PNG images, 8 or 16, see
+	// png.RenderOptions.BitDepth. The directory entries report the matching
+	// BitCount, e.g. 64 for 16-bit images with alpha. The zero value means 8.
+	BitDepth int
+
+	svgHash    string // Content hash of the parsed SVG markup, for the cache
+	bmpMaxSize int    // Sizes up to this are stored as BMP entries, set by CreateIcoCompatOptions
+}
+
 // ICONDIREntry represents a single icon in the icon directory
 type ICONDIREntry struct {
 	Width       uint8  // Width in pixels (0 = 256)
@@ -38,25 +115,304 @@ type ICONDIREntry struct {
 //
 // Returns an error if SVG processing or file writing fails.
 func CreateIco(svgPath string, outputPath string) error {
-	var imageData [][]byte
-	var entries []ICONDIREntry
+	return CreateIcoSizes(svgPath, outputPath, IconSizes)
+}
+
+// CreateIcoContext generates a Windows ICO file like CreateIco but stops as soon
+// as ctx is cancelled.
+//
+// A render still running when ctx is cancelled is abandoned, so the call
+// returns right away and a cancelled conversion never starts another
+// (potentially expensive) render.
+//
+// Returns the context error if ctx is cancelled, or an error if SVG processing
+// or file writing fails.
+func CreateIcoContext(ctx context.Context, svgPath string, outputPath string) error {
+	data, _, err := encodeIco(ctx, svgPath, IconSizes, 1, Options{})
+	if err != nil {
+		return err
+	}
+
+	// Write the encoded icon to the output file
+	err = atomicfile.WriteFile(outputPath, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateIcoSizes generates a Windows ICO file from an SVG source containing
+// exactly the given icon sizes.
+//
+// The sizes are normalized like NormalizeSizes, so the directory entries are
+// always in ascending order and a duplicated size is only embedded once.
+// A single size such as []int{32} gives the smallest valid file: a 6-byte
+// header, one 16-byte directory entry and the image at offset 22.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - outputPath: Path where the ICO file will be written
+//   - sizes: Icon sizes in pixels, each between 1 and 256
+//
+// Returns an error if a size is out of range or SVG processing or file writing fails.
+func CreateIcoSizes(svgPath string, outputPath string, sizes []int) error {
+	return CreateIcoOptions(svgPath, outputPath, sizes, Options{})
+}
+
+// CreateIcoOptions generates a Windows ICO file like CreateIcoSizes using the
+// given options.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - outputPath: Path where the ICO file will be written
+//   - sizes: Icon sizes in pixels, each between 1 and 256
+//   - opts: Options controlling the generation
+//
+// Returns an error if a size is out of range or SVG processing or file writing fails.
+func CreateIcoOptions(svgPath string, outputPath string, sizes []int, opts Options) error {
+	return CreateIcoOptionsContext(context.Background(), svgPath, outputPath, sizes, opts)
+}
+
+// CreateIcoOptionsContext generates a Windows ICO file like CreateIcoOptions
+// but stops as soon as ctx is cancelled.
+//
+// A render still running when ctx is cancelled is abandoned, and the context
+// is checked again before the output file is created, so a cancelled conversion
+// writes nothing.
+//
+// Returns the context error if ctx is cancelled, or an error if a size is out
+// of range or SVG processing or file writing fails.
+func CreateIcoOptionsContext(ctx context.Context, svgPath string, outputPath string, sizes []int, opts Options) error {
+	sizes, imageData, err := renderIco(ctx, svgPath, sizes, opts)
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(outputPath, 0644, opts.DirectWrite, func(w io.Writer) error {
+		return writeIco(w, sizes, imageData)
+	})
+}
+
+// WriteIcoTo generates a Windows ICO file from an SVG source and writes it to w.
+//
+// Only the rendered PNG images are held in memory, since their sizes are needed
+// to compute the directory offsets. The container itself is written straight
+// to w, which makes this suitable for HTTP responses or archive entries.
+//
+// Parameters:
+//   - w: Destination of the ICO byte stream
+//   - svgPath: Path to the source SVG file
+//   - sizes: Icon sizes in pixels, each between 1 and 256
+//
+// Returns an error if a size is out of range, SVG processing fails or w
+// reports a write error.
+func WriteIcoTo(w io.Writer, svgPath string, sizes []int) error {
+	sizes, imageData, err := renderIco(context.Background(), svgPath, sizes, Options{})
+	if err != nil {
+		return err
+	}
+	return writeIco(w, sizes, imageData)
+}
+
+// renderIco renders the images of an ICO file with the given sizes and
+// options. Returns the normalized sizes with their PNG images, or the
+// context error if ctx is cancelled before all sizes are rendered.
+func renderIco(ctx context.Context, svgPath string, sizes []int, opts Options) ([]int, [][]byte, error) {
+	if err := ValidateSizes(sizes); err != nil {
+		return nil, nil, err
+	}
+	sizes = NormalizeSizes(sizes)
+
+	// Parse the SVG once and reuse it for every size
+	icon, err := parseSvg(svgPath, &opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	imageData, err := renderSizes(ctx, icon, sizes, 1, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return sizes, imageData, nil
+}
+
+// EncodeIco generates a Windows ICO file from an SVG source and returns its bytes
+// instead of writing them to disk.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - sizes: Icon sizes in pixels, each between 1 and 256
+//
+// Returns the complete ICO byte stream, or an error if a size is out of range
+// or SVG processing fails.
+func EncodeIco(svgPath string, sizes []int) ([]byte, error) {
+	data, _, err := encodeIco(context.Background(), svgPath, sizes, 1, Options{})
+	return data, err
+}
+
+// EncodeIcoReader generates a Windows ICO file from SVG markup read from r
+// and returns its bytes.
+//
+// Parameters:
+//   - r: Reader providing the SVG markup
+//   - sizes: Icon sizes in pixels, each between 1 and 256
+//
+// Returns the complete ICO byte stream, or an error if a size is out of range
+// or SVG processing fails.
+func EncodeIcoReader(r io.Reader, sizes []int) ([]byte, error) {
+	return EncodeIcoReaderOptions(r, sizes, Options{})
+}
+
+// EncodeIcoReaderOptions generates a Windows ICO file from SVG markup read
+// from r like EncodeIcoReader using the given options.
+//
+// Parameters:
+//   - r: Reader providing the SVG markup
+//   - sizes: Icon sizes in pixels, each between 1 and 256
+//   - opts: Options controlling the generation
+//
+// Returns the complete ICO byte stream, or an error if a size is out of range
+// or SVG processing fails.
+func EncodeIcoReaderOptions(r io.Reader, sizes []int, opts Options) ([]byte, error) {
+	return EncodeIcoReaderOptionsContext(context.Background(), r, sizes, opts)
+}
+
+// EncodeIcoReaderOptionsContext generates a Windows ICO file like
+// EncodeIcoReaderOptions but stops as soon as ctx is cancelled.
+//
+// A render still running when ctx is cancelled is abandoned, so the call
+// returns right away.
+//
+// Returns the complete ICO byte stream, the context error if ctx is
+// cancelled, or an error if a size is out of range or SVG processing fails.
+func EncodeIcoReaderOptionsContext(ctx context.Context, r io.Reader, sizes []int, opts Options) ([]byte, error) {
+	icon, err := parseSvgReader(r, &opts)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := encodeIcon(ctx, icon, sizes, 1, opts)
+	return data, err
+}
+
+// CreateIcoFromBytes generates a Windows ICO file from SVG markup held in
+// memory and returns its bytes, for callers such as web services that never
+// touch the file system.
+//
+// Parameters:
+//   - svg: SVG markup
+//   - sizes: Icon sizes in pixels, each between 1 and 256
+//
+// Returns the complete ICO byte stream, or an error if a size is out of range
+// or SVG processing fails.
+func CreateIcoFromBytes(svg []byte, sizes []int) ([]byte, error) {
+	return EncodeIcoReader(bytes.NewReader(svg), sizes)
+}
+
+// EncodeIcoIcon generates a Windows ICO file from an already parsed icon and
+// returns its bytes, so one parsed SVG can be encoded into several formats.
+//
+// Parameters:
+//   - icon: Icon parsed with png.ParseSvg or png.ParseSvgReader
+//   - sizes: Icon sizes in pixels, each between 1 and 256
+//   - opts: Options controlling the generation
+//
+// Returns the complete ICO byte stream, or an error if a size is out of range
+// or rendering fails.
+func EncodeIcoIcon(icon *oksvg.SvgIcon, sizes []int, opts Options) ([]byte, error) {
+	return EncodeIcoIconContext(context.Background(), icon, sizes, opts)
+}
+
+// EncodeIcoIconContext generates a Windows ICO file from an already parsed
+// icon like EncodeIcoIcon but stops as soon as ctx is cancelled.
+//
+// A render still running when ctx is cancelled is abandoned, so the call
+// returns right away.
+//
+// Returns the complete ICO byte stream, the context error if ctx is
+// cancelled, or an error if a size is out of range or rendering fails.
+func EncodeIcoIconContext(ctx context.Context, icon *oksvg.SvgIcon, sizes []int, opts Options) ([]byte, error) {
+	data, _, err := encodeIcon(ctx, icon, sizes, 1, opts)
+	return data, err
+}
+
+// encodeIco parses the SVG file and encodes it as an ICO file.
+func encodeIco(ctx context.Context, svgPath string, sizes []int, workers int, opts Options) ([]byte, IcoResult, error) {
+	if err := ValidateSizes(sizes); err != nil {
+		return nil, IcoResult{}, err
+	}
+
+	// Parse the SVG once and reuse it for every size
+	icon, err := parseSvg(svgPath, &opts)
+	if err != nil {
+		return nil, IcoResult{}, err
+	}
+
+	return encodeIcon(ctx, icon, sizes, workers, opts)
+}
+
+// encodeIcon renders and encodes an ICO file from a parsed icon using up to
+// workers concurrent renders, stopping as soon as ctx is cancelled.
+// Returns the encoded file together with a description of its entries.
+func encodeIcon(ctx context.Context, icon *oksvg.SvgIcon, sizes []int, workers int, opts Options) ([]byte, IcoResult, error) {
+	if err := ValidateSizes(sizes); err != nil {
+		return nil, IcoResult{}, err
+	}
+	sizes = NormalizeSizes(sizes)
 
 	// Generate png byte array for all sizes
-	for _, currentSize := range IconSizes {
-		pngData, err := png.SvgToPng(svgPath, currentSize)
-		if err != nil {
-			return err
-		}
-		imageData = append(imageData, pngData)
+	imageData, err := renderSizes(ctx, icon, sizes, workers, opts)
+	if err != nil {
+		return nil, IcoResult{}, err
 	}
 
+	buffer := &bytes.Buffer{}
+	if err := writeIco(buffer, sizes, imageData); err != nil {
+		return nil, IcoResult{}, err
+	}
+
+	return buffer.Bytes(), newIcoResult(sizes, imageData, buffer.Len()), nil
+}
+
+// The image types stored in the ICONDIR header
+const (
+	typeIcon   = 1 // .ico
+	typeCursor = 2 // .cur
+)
+
+// writeIco writes an ICO container holding the given PNG images to w.
+// imageData[i] must contain the encoded image for sizes[i].
+// Returns the first error reported by the writer.
+func writeIco(w io.Writer, sizes []int, imageData [][]byte) error {
+	return writeIconDir(w, typeIcon, sizes, imageData, 0, 0)
+}
+
+// writeIconDir writes an ICO or CUR container of the given image type holding
+// the PNG images to w. Cursors store hotspotX and hotspotY in the Planes and
+// BitCount fields of every entry, icons ignore them.
+// Returns the first error reported by the writer.
+func writeIconDir(w io.Writer, imageType uint16, sizes []int, imageData [][]byte, hotspotX uint16, hotspotY uint16) error {
+	// Sizes outside the range don't fit the uint8 width and height fields
+	// and would silently be written as a different size
+	if err := ValidateSizes(sizes); err != nil {
+		return err
+	}
+	// Every directory entry points at the image with the same index
+	if len(imageData) != len(sizes) {
+		return fmt.Errorf("got %d images for %d ICO sizes", len(imageData), len(sizes))
+	}
+
+	var entries []ICONDIREntry
+
 	// Calculate offsets for image data
-	headerSize := 6                    // ICONDIR header (6 bytes)
-	entriesSize := len(IconSizes) * 16 // ICONDIRENTRY array (16 bytes per entry)
+	headerSize := 6                // ICONDIR header (6 bytes)
+	entriesSize := len(sizes) * 16 // ICONDIRENTRY array (16 bytes per entry)
 	currentOffset := uint32(headerSize + entriesSize)
 
 	// Create directory entries
-	for i, currentSize := range IconSizes {
+	for i, currentSize := range sizes {
 		width := uint8(currentSize)
 		height := uint8(currentSize)
 
@@ -68,47 +424,92 @@ func CreateIco(svgPath string, outputPath string) error {
 		entry := ICONDIREntry{
 			Width:       width,
 			Height:      height,
-			ColorCount:  0,  // 0 for >= 8bpp (we use 32bpp RGBA)
-			Reserved:    0,  // Always 0
-			Planes:      1,  // Always 1 for PNG
-			BitCount:    32, // 32bpp for RGBA PNG
+			ColorCount:  0, // 0 for >= 8bpp
+			Reserved:    0, // Always 0
+			Planes:      1, // Always 1 for PNG
+			BitCount:    bitCount(imageData[i]),
 			BytesInRes:  uint32(len(imageData[i])),
 			ImageOffset: currentOffset,
 		}
+		if imageType == typeCursor {
+			entry.Planes, entry.BitCount = hotspotX, hotspotY
+		}
 		entries = append(entries, entry)
 		currentOffset += uint32(len(imageData[i]))
 	}
 
-	buffer := &bytes.Buffer{}
-
 	// ICONDIR header
-	// 2 bytes reserved, 2 bytes type=1 (icon), 2 bytes count
-	binary.Write(buffer, binary.LittleEndian, uint16(0))              // reserved
-	binary.Write(buffer, binary.LittleEndian, uint16(1))              // type = 1 (icon)
-	binary.Write(buffer, binary.LittleEndian, uint16(len(IconSizes))) // count
+	// 2 bytes reserved, 2 bytes type (1 = icon, 2 = cursor), 2 bytes count
+	header := []uint16{
+		0,                  // reserved
+		imageType,          // type
+		uint16(len(sizes)), // count
+	}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
 
-	// Write ICONDIRENTRY array
+	// Write ICONDIRENTRY array, the struct layout matches the 16 byte on-disk entry
 	for _, currentEntry := range entries {
-		binary.Write(buffer, binary.LittleEndian, currentEntry.Width)
-		binary.Write(buffer, binary.LittleEndian, currentEntry.Height)
-		binary.Write(buffer, binary.LittleEndian, currentEntry.ColorCount)
-		binary.Write(buffer, binary.LittleEndian, currentEntry.Reserved)
-		binary.Write(buffer, binary.LittleEndian, currentEntry.Planes)
-		binary.Write(buffer, binary.LittleEndian, currentEntry.BitCount)
-		binary.Write(buffer, binary.LittleEndian, currentEntry.BytesInRes)
-		binary.Write(buffer, binary.LittleEndian, currentEntry.ImageOffset)
+		if err := binary.Write(w, binary.LittleEndian, currentEntry); err != nil {
+			return err
+		}
 	}
 
 	// Write all .png image data
 	for _, currentPng := range imageData {
-		buffer.Write(currentPng)
+		if _, err := w.Write(currentPng); err != nil {
+			return err
+		}
 	}
 
-	// Write the buffer to the output file
-	err := os.WriteFile(outputPath, buffer.Bytes(), 0644)
-	if err != nil {
-		return err
+	return nil
+}
+
+// pngSignature starts every PNG file.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// bitCount returns the bits per pixel of an encoded image for its directory
+// entry. PNG images are described by the bit depth and color type in their
+// IHDR chunk, since the encoder stores opaque images without an alpha channel.
+// Anything else is one of our 32bpp BMP images.
+func bitCount(data []byte) uint16 {
+	// Signature (8), chunk length (4), "IHDR" (4), width (4), height (4),
+	// bit depth (1), color type (1)
+	if len(data) < 26 || !bytes.HasPrefix(data, pngSignature) || string(data[12:16]) != "IHDR" {
+		return 32
 	}
 
+	bitDepth := uint16(data[24])
+	switch data[25] {
+	case 0, 3: // Grayscale, palette
+		return bitDepth
+	case 2: // RGB
+		return bitDepth * 3
+	case 4: // Grayscale with alpha
+		return bitDepth * 2
+	case 6: // RGBA
+		return bitDepth * 4
+	}
+	return 32
+}
+
+// ValidateSizes checks that sizes is non-empty and that every size can be
+// represented in an ICO directory entry.
+func ValidateSizes(sizes []int) error {
+	if len(sizes) == 0 {
+		return errors.New("no ICO sizes given")
+	}
+	for _, size := range sizes {
+		if size < MinIconSize || size > MaxIconSize {
+			return fmt.Errorf("invalid ICO size: must be %d-%d, got %d", MinIconSize, MaxIconSize, size)
+		}
+	}
 	return nil
 }
+
+// NormalizeSizes returns a sorted copy of sizes without duplicates. This is
+// the order in which the sizes are stored in a generated ICO file.
+func NormalizeSizes(sizes []int) []int {
+	return slices.Compact(slices.Sorted(slices.Values(sizes)))
+}
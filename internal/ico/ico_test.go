@@ -0,0 +1,458 @@
+package ico
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	svgpng "github.com/julian-bruyers/svg2icon/internal/png"
+	"image"
+	"image/png"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSvg is a small opaque icon used as input by the tests.
+const testSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">
+<rect width="64" height="64" rx="12" fill="#2b6cb0"/>
+<circle cx="32" cy="32" r="18" fill="#ffffff"/>
+</svg>
+`
+
+// writeTestSvg writes testSvg into a temporary directory and returns its path.
+func writeTestSvg(t testing.TB) string {
+	t.Helper()
+	return writeSvg(t, testSvg)
+}
+
+// writeSvg writes svg into a temporary directory and returns its path.
+func writeSvg(t testing.TB, svg string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "icon.svg")
+	if err := os.WriteFile(path, []byte(svg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEncodeIcoSingleEntry(t *testing.T) {
+	svgPath := writeTestSvg(t)
+
+	tests := []struct {
+		size   int
+		width  uint8 // Width and Height field, 0 for 256
+		offset uint32
+	}{
+		{size: 32, width: 32, offset: 6 + 16},
+		{size: 1, width: 1, offset: 6 + 16},
+		{size: 256, width: 0, offset: 6 + 16},
+	}
+	for _, test := range tests {
+		data, err := EncodeIco(svgPath, []int{test.size})
+		if err != nil {
+			t.Fatalf("EncodeIco(%dpx): %v", test.size, err)
+		}
+
+		entries, err := ReadIco(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("ReadIco(%dpx): %v", test.size, err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("%dpx: got %d entries, want 1", test.size, len(entries))
+		}
+		entry := entries[0]
+		if entry.Width != test.width || entry.Height != test.width {
+			t.Errorf("%dpx: got %dx%d, want %dx%d", test.size, entry.Width, entry.Height, test.width, test.width)
+		}
+		if entry.ImageOffset != test.offset {
+			t.Errorf("%dpx: got offset %d, want %d", test.size, entry.ImageOffset, test.offset)
+		}
+		if end := int(entry.ImageOffset) + int(entry.BytesInRes); end != len(data) {
+			t.Errorf("%dpx: image ends at %d, file is %d bytes", test.size, end, len(data))
+		}
+
+		image := data[entry.ImageOffset:]
+		if int(entry.BytesInRes) != len(image) {
+			t.Errorf("%dpx: BytesInRes is %d, image is %d bytes", test.size, entry.BytesInRes, len(image))
+		}
+		decoded, err := png.Decode(bytes.NewReader(image))
+		if err != nil {
+			t.Fatalf("%dpx: image doesn't decode: %v", test.size, err)
+		}
+		if bounds := decoded.Bounds(); bounds.Dx() != test.size || bounds.Dy() != test.size {
+			t.Errorf("%dpx: image is %dx%d", test.size, bounds.Dx(), bounds.Dy())
+		}
+	}
+}
+
+func TestEncodeIcoBitCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		svg      string
+		bitCount uint16
+	}{
+		{
+			name:     "opaque grayscale",
+			svg:      `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 8 8"><rect width="8" height="8" fill="#808080"/><rect width="4" height="4" fill="#202020"/></svg>`,
+			bitCount: 24,
+		},
+		{
+			name:     "grayscale with alpha",
+			svg:      `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 8 8"><circle cx="4" cy="4" r="3" fill="#808080"/></svg>`,
+			bitCount: 32,
+		},
+		{name: "opaque color", svg: testSvg, bitCount: 24},
+		{
+			name:     "color with alpha",
+			svg:      `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 8 8"><circle cx="4" cy="4" r="3" fill="#c03020"/></svg>`,
+			bitCount: 32,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := EncodeIco(writeSvg(t, test.svg), IconSizes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries, err := ReadIco(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, entry := range entries {
+				width, _ := entry.Size()
+				image := data[entry.ImageOffset : entry.ImageOffset+entry.BytesInRes]
+				if entry.Planes != 1 {
+					t.Errorf("%dpx: Planes is %d, want 1", width, entry.Planes)
+				}
+				if entry.BitCount != test.bitCount {
+					t.Errorf("%dpx: BitCount is %d, want %d", width, entry.BitCount, test.bitCount)
+				}
+				if ihdr := bitCount(image); entry.BitCount != ihdr {
+					t.Errorf("%dpx: BitCount is %d, the PNG header has %d", width, entry.BitCount, ihdr)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeSizes(t *testing.T) {
+	svgPath := writeTestSvg(t)
+
+	tests := []struct {
+		sizes []int
+		want  []int
+	}{
+		{sizes: []int{32}, want: []int{32}},
+		{sizes: []int{16, 32, 48}, want: []int{16, 32, 48}},
+		{sizes: []int{32, 16, 32, 256}, want: []int{16, 32, 256}},
+		{sizes: []int{256, 256, 256}, want: []int{256}},
+		{sizes: []int{48, 1, 24, 1, 16, 48}, want: []int{1, 16, 24, 48}},
+	}
+	for _, test := range tests {
+		input := slices.Clone(test.sizes)
+		if got := NormalizeSizes(input); !slices.Equal(got, test.want) {
+			t.Errorf("NormalizeSizes(%v) = %v, want %v", test.sizes, got, test.want)
+		}
+		if !slices.Equal(input, test.sizes) {
+			t.Errorf("NormalizeSizes(%v) modified its argument to %v", test.sizes, input)
+		}
+
+		// The ICO file holds one entry per unique size in ascending order
+		data, err := EncodeIco(svgPath, test.sizes)
+		if err != nil {
+			t.Fatalf("EncodeIco(%v): %v", test.sizes, err)
+		}
+		entries, err := ReadIco(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, entry := range entries {
+			width, _ := entry.Size()
+			got = append(got, width)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("EncodeIco(%v) has entries %v, want %v", test.sizes, got, test.want)
+		}
+	}
+}
+
+// discardLogger is a Logger that drops every message.
+type discardLogger struct{}
+
+func (discardLogger) Logf(string, ...any) {}
+
+func TestCreateIcoBrokenLink(t *testing.T) {
+	dir := t.TempDir()
+	svgPath := filepath.Join(dir, "icon.svg")
+	if err := os.Symlink("missing.svg", svgPath); err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"default", Options{}},
+		{"strict", Options{Strict: true}},
+		{"logger", Options{Logger: discardLogger{}}},
+		{"cache", Options{CacheDir: t.TempDir()}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CreateIcoOptions(svgPath, filepath.Join(dir, "icon.ico"), IconSizes, test.opts)
+			if !errors.Is(err, fs.ErrNotExist) {
+				t.Fatalf("got %v, want a not exist error", err)
+			}
+			if want := fmt.Sprintf("open %s: broken symbolic link to missing.svg", svgPath); !strings.HasPrefix(err.Error(), want) {
+				t.Errorf("got %q, want it to start with %q", err, want)
+			}
+		})
+	}
+}
+
+func TestEncodeIcoBitDepth(t *testing.T) {
+	svgPath := writeSvg(t, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 8 8"><circle cx="4" cy="4" r="3" fill="#c03020"/></svg>`)
+	sizes := []int{16, 256}
+
+	tests := []struct {
+		bitDepth int
+		ihdr     byte   // Bit depth in the IHDR chunk of every image
+		bitCount uint16 // 0 if encoding fails
+	}{
+		{bitDepth: 0, ihdr: 8, bitCount: 32},
+		{bitDepth: 8, ihdr: 8, bitCount: 32},
+		{bitDepth: 16, ihdr: 16, bitCount: 64},
+		{bitDepth: 4},
+	}
+	for _, test := range tests {
+		output := filepath.Join(t.TempDir(), "icon.ico")
+		err := CreateIcoOptions(svgPath, output, sizes, Options{BitDepth: test.bitDepth})
+		if test.bitCount == 0 {
+			if err == nil {
+				t.Errorf("bit depth %d: encoding succeeded", test.bitDepth)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("bit depth %d: %v", test.bitDepth, err)
+		}
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := ReadIco(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, entry := range entries {
+			width, _ := entry.Size()
+			image := data[entry.ImageOffset : entry.ImageOffset+entry.BytesInRes]
+			if image[24] != test.ihdr {
+				t.Errorf("bit depth %d, %dpx: IHDR bit depth %d, want %d", test.bitDepth, width, image[24], test.ihdr)
+			}
+			if entry.BitCount != test.bitCount {
+				t.Errorf("bit depth %d, %dpx: BitCount %d, want %d", test.bitDepth, width, entry.BitCount, test.bitCount)
+			}
+		}
+	}
+}
+
+// errWriteFailed is returned by failingWriter once its limit is reached.
+var errWriteFailed = errors.New("write failed")
+
+// failingWriter accepts limit bytes and fails every write after that.
+type failingWriter struct {
+	limit   int
+	written int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.limit {
+		n := w.limit - w.written
+		w.written = w.limit
+		return n, errWriteFailed
+	}
+	w.written += len(p)
+	return len(p), nil
+}
+
+func TestWriteIcoToFailingWriter(t *testing.T) {
+	svgPath := writeTestSvg(t)
+	sizes := []int{16, 32}
+	entriesEnd := 6 + 16*len(sizes)
+
+	tests := []struct {
+		name  string
+		limit int
+	}{
+		{"header", 0},
+		{"inside header", 3},
+		{"first entry", 6},
+		{"second entry", 6 + 16 + 8},
+		{"first image", entriesEnd},
+		{"inside image data", entriesEnd + 100},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := &failingWriter{limit: test.limit}
+			err := WriteIcoTo(w, svgPath, sizes)
+			if !errors.Is(err, errWriteFailed) {
+				t.Fatalf("WriteIcoTo after %d bytes: got %v, want %v", test.limit, err, errWriteFailed)
+			}
+		})
+	}
+
+	// A writer with enough room succeeds
+	data, err := EncodeIco(svgPath, sizes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIcoTo(&failingWriter{limit: len(data)}, svgPath, sizes); err != nil {
+		t.Errorf("WriteIcoTo with room for %d bytes: %v", len(data), err)
+	}
+}
+
+// countingWriter counts the bytes written through it to w.
+type countingWriter struct {
+	w       http.ResponseWriter
+	written int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.written += n
+	return n, err
+}
+
+func TestWriteIcoToResponse(t *testing.T) {
+	svgPath := writeTestSvg(t)
+
+	tests := [][]int{IconSizes, {32}, {256, 16}}
+	for _, sizes := range tests {
+		want, err := EncodeIco(svgPath, sizes)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Serve the icon with the length of the encoded file, as a handler would
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/x-icon")
+			w.Header().Set("Content-Length", strconv.Itoa(len(want)))
+			counter := &countingWriter{w: w}
+			if err := WriteIcoTo(counter, svgPath, sizes); err != nil {
+				t.Errorf("WriteIcoTo(%v): %v", sizes, err)
+			}
+			if counter.written != len(want) {
+				t.Errorf("%v: wrote %d bytes, Content-Length is %d", sizes, counter.written, len(want))
+			}
+		})
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/favicon.ico", nil))
+
+		response := recorder.Result()
+		if response.ContentLength != int64(recorder.Body.Len()) {
+			t.Errorf("%v: Content-Length %d, body %d bytes", sizes, response.ContentLength, recorder.Body.Len())
+		}
+		if !bytes.Equal(recorder.Body.Bytes(), want) {
+			t.Errorf("%v: response body differs from EncodeIco", sizes)
+		}
+	}
+}
+
+func TestCreateIcoContextSlowRender(t *testing.T) {
+	svgPath := writeTestSvg(t)
+	output := filepath.Join(t.TempDir(), "icon.ico")
+
+	// Every render blocks in PostProcess for seconds, or until the test ends
+	release := make(chan struct{})
+	defer close(release)
+	opts := Options{PostProcess: func(int, *image.RGBA) error {
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+		return nil
+	}}
+
+	const timeout = 50 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	err := CreateIcoOptionsContext(ctx, svgPath, output, IconSizes, opts)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed > timeout+time.Second {
+		t.Errorf("returned %s after the deadline", elapsed-timeout)
+	}
+	if _, err := os.Stat(output); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("output was written: %v", err)
+	}
+}
+
+// recordingLogger is a Logger that keeps every message.
+type recordingLogger struct{ messages []string }
+
+func (l *recordingLogger) Logf(format string, args ...any) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+// featureMessages returns the logged messages about unsupported features.
+func (l *recordingLogger) featureMessages() []string {
+	var messages []string
+	for _, message := range l.messages {
+		if strings.HasPrefix(message, svgpng.ErrUnsupportedFeature.Error()) {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+func TestCreateIcoFeatureCheck(t *testing.T) {
+	svgPath := writeSvg(t, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">
+<rect width="64" height="64" fill="#2b6cb0"/>
+<text x="8" y="40">Hi</text>
+</svg>`)
+	dir := t.TempDir()
+
+	// Strict rejects the feature found in the file that is parsed
+	err := CreateIcoOptions(svgPath, filepath.Join(dir, "strict.ico"), []int{16}, Options{Strict: true})
+	if !errors.Is(err, svgpng.ErrUnsupportedFeature) || !strings.Contains(err.Error(), svgPath) {
+		t.Fatalf("strict: got %v, want an ErrUnsupportedFeature naming %s", err, svgPath)
+	}
+
+	// Otherwise every feature is logged exactly once
+	logger := &recordingLogger{}
+	if err := CreateIcoOptions(svgPath, filepath.Join(dir, "logged.ico"), []int{16, 32}, Options{Logger: logger}); err != nil {
+		t.Fatal(err)
+	}
+	if got := logger.featureMessages(); len(got) != 1 {
+		t.Errorf("logged %d feature messages, want 1: %q", len(got), got)
+	}
+
+	// Callers that checked the markup themselves skip both
+	logger = &recordingLogger{}
+	opts := Options{Strict: true, Logger: logger, SkipFeatureCheck: true}
+	if err := CreateIcoOptions(svgPath, filepath.Join(dir, "skipped.ico"), []int{16}, opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := logger.featureMessages(); len(got) != 0 {
+		t.Errorf("logged feature messages %q with SkipFeatureCheck", got)
+	}
+	if len(logger.messages) == 0 {
+		t.Error("render messages weren't logged with SkipFeatureCheck")
+	}
+}
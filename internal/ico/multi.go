@@ -0,0 +1,70 @@
+package ico
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/atomicfile"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"github.com/srwiley/oksvg"
+	"maps"
+	"slices"
+)
+
+// CreateIcoMulti generates a Windows ICO file whose sizes each come from their
+// own SVG source.
+//
+// Small sizes hand-tuned as simplified SVGs stay crisper than a detailed SVG
+// scaled down, so e.g. 16 and 32 can come from logo-small.svg while the
+// larger sizes use logo.svg. Every distinct source is parsed once.
+//
+// Parameters:
+//   - sources: SVG path for every size to embed, each size between 1 and 256
+//   - outputPath: Path where the ICO file will be written
+//
+// Returns an error if a size is out of range or has no source, or if SVG
+// processing or file writing fails.
+func CreateIcoMulti(sources map[int]string, outputPath string) error {
+	sizes := slices.Sorted(maps.Keys(sources))
+	if err := ValidateSizes(sizes); err != nil {
+		return err
+	}
+	for _, size := range sizes {
+		if sources[size] == "" {
+			return fmt.Errorf("no SVG source given for ICO size %d", size)
+		}
+	}
+
+	icons := map[string]*oksvg.SvgIcon{}
+	imageData := make([][]byte, len(sizes))
+	for i, size := range sizes {
+		svgPath := sources[size]
+		icon, ok := icons[svgPath]
+		if !ok {
+			var err error
+			icon, err = png.ParseSvg(svgPath)
+			if err != nil {
+				return err
+			}
+			icons[svgPath] = icon
+		}
+
+		pngData, err := png.RenderIcon(icon, size)
+		if err != nil {
+			return fmt.Errorf("rendering ico entry %dpx from %s: %w", size, svgPath, err)
+		}
+		imageData[i] = pngData
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := writeIco(buffer, sizes, imageData); err != nil {
+		return err
+	}
+
+	// Write the encoded icon to the output file
+	err := atomicfile.WriteFile(outputPath, buffer.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
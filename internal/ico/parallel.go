@@ -0,0 +1,145 @@
+package ico
+
+import (
+	"context"
+	"fmt"
+	"github.com/julian-bruyers/svg2icon/internal/atomicfile"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"github.com/srwiley/oksvg"
+	"runtime"
+	"sync"
+)
+
+// CreateIcoParallel generates a Windows ICO file like CreateIco but renders the
+// icon sizes concurrently.
+//
+// The number of workers is bounded by runtime.NumCPU(); a value of zero or less
+// uses all available CPUs. Every rendered image is stored by its size index, so
+// the resulting file is byte-identical to the one produced by CreateIco.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - outputPath: Path where the ICO file will be written
+//   - workers: Maximum number of sizes rendered at the same time
+//
+// Returns an error if SVG processing or file writing fails. The first failing
+// render cancels all renders that haven't started yet.
+func CreateIcoParallel(svgPath string, outputPath string, workers int) error {
+	if workers <= 0 || workers > runtime.NumCPU() {
+		workers = runtime.NumCPU()
+	}
+
+	data, _, err := encodeIco(context.Background(), svgPath, IconSizes, workers, Options{})
+	if err != nil {
+		return err
+	}
+
+	// Write the encoded icon to the output file
+	err = atomicfile.WriteFile(outputPath, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// renderSizes rasterizes the icon at every size using a pool of workers.
+// The result at index i always belongs to sizes[i], regardless of the order
+// in which the renders finish. A single worker renders the sizes in order.
+// Sizes up to opts.bmpMaxSize are encoded as BMP entries, the others as PNG.
+func renderSizes(ctx context.Context, icon *oksvg.SvgIcon, sizes []int, workers int, opts Options) ([][]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(sizes) {
+		workers = len(sizes)
+	}
+
+	renderOpts := png.DefaultRenderOptions()
+	renderOpts.Logger = opts.Logger
+	renderOpts.CompressionLevel = opts.CompressionLevel
+	renderOpts.PostProcess = opts.PostProcess
+	renderOpts.PerSize = opts.PerSize
+	renderOpts.BitDepth = opts.BitDepth
+	renderOpts.RejectBlank = opts.RejectBlank
+
+	imageData := make([][]byte, len(sizes))
+	jobs := make(chan int)
+
+	var firstErr error
+	var errOnce sync.Once
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	// Report progress under a lock so done only ever increases
+	var progressMu sync.Mutex
+	done := 0
+	progress := func() {
+		if opts.Progress == nil {
+			return
+		}
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		done++
+		opts.Progress(done, len(sizes))
+	}
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := ctx.Err(); err != nil {
+					fail(err)
+					return
+				}
+
+				entryData, err := png.RenderContext(ctx, func() ([]byte, error) {
+					if sizes[i] <= opts.bmpMaxSize {
+						return renderDib(icon, sizes[i], renderOpts)
+					}
+					return png.RenderIconCached(icon, opts.svgHash, sizes[i], renderOpts, opts.CacheDir)
+				})
+				if err != nil {
+					if ctx.Err() != nil {
+						fail(ctx.Err())
+						return
+					}
+					fail(fmt.Errorf("rendering ico entry %dpx: %w", sizes[i], err))
+					return
+				}
+				imageData[i] = entryData
+				progress()
+			}
+		}()
+	}
+
+	// Hand out the sizes in order until everything is queued or a worker failed
+	for i := range sizes {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return imageData, nil
+}
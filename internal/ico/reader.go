@@ -0,0 +1,52 @@
+package ico
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotIco is returned when the input doesn't start with an ICO header.
+var ErrNotIco = errors.New("input is not an ICO file")
+
+// ReadIco parses the ICONDIR header and directory entries of an ICO file.
+//
+// Only the header and directory are read from r, the image data that follows
+// is left unread. Use Size to get the pixel dimensions of an entry, since the
+// raw Width and Height fields store 256 as 0.
+//
+// Parameters:
+//   - r: Reader positioned at the start of the ICO file
+//
+// Returns the directory entries in file order, ErrNotIco if the header isn't
+// a valid icon header, or an error if the directory is truncated.
+func ReadIco(r io.Reader) ([]ICONDIREntry, error) {
+	// ICONDIR header: reserved, type, count
+	var header [3]uint16
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("reading ICO header: %w", err)
+	}
+	if header[0] != 0 || header[1] != 1 {
+		return nil, ErrNotIco
+	}
+
+	entries := make([]ICONDIREntry, header[2])
+	if err := binary.Read(r, binary.LittleEndian, entries); err != nil {
+		return nil, fmt.Errorf("reading ICO directory: %w", err)
+	}
+	return entries, nil
+}
+
+// Size returns the pixel dimensions of the entry, decoding the 0 that ICO
+// files store for 256 pixels.
+func (e ICONDIREntry) Size() (width int, height int) {
+	width, height = int(e.Width), int(e.Height)
+	if width == 0 {
+		width = 256
+	}
+	if height == 0 {
+		height = 256
+	}
+	return width, height
+}
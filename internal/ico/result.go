@@ -0,0 +1,52 @@
+package ico
+
+import (
+	"context"
+	"github.com/julian-bruyers/svg2icon/internal/atomicfile"
+)
+
+// IcoResult describes the contents of a generated ICO file.
+type IcoResult struct {
+	Sizes      []int // Icon sizes in pixels, in directory order
+	EntryBytes []int // Encoded image size in bytes of each entry
+	TotalBytes int   // Size of the complete ICO file in bytes
+}
+
+// CreateIcoResult generates a Windows ICO file like CreateIco and reports what
+// was written.
+//
+// The result lets callers log or assert on the generated entries, e.g. to
+// catch a suspiciously small 256x256 image caused by a failed render.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - outputPath: Path where the ICO file will be written
+//
+// Returns the description of the written file, or an error if SVG processing
+// or file writing fails.
+func CreateIcoResult(svgPath string, outputPath string) (IcoResult, error) {
+	data, result, err := encodeIco(context.Background(), svgPath, IconSizes, 1, Options{})
+	if err != nil {
+		return IcoResult{}, err
+	}
+
+	// Write the encoded icon to the output file
+	err = atomicfile.WriteFile(outputPath, data, 0644)
+	if err != nil {
+		return IcoResult{}, err
+	}
+
+	return result, nil
+}
+
+// newIcoResult builds the result for the given sizes and their encoded images.
+func newIcoResult(sizes []int, imageData [][]byte, totalBytes int) IcoResult {
+	result := IcoResult{
+		Sizes:      append([]int(nil), sizes...),
+		TotalBytes: totalBytes,
+	}
+	for _, data := range imageData {
+		result.EntryBytes = append(result.EntryBytes, len(data))
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+package outputs
+
+import (
+	"errors"
+	"github.com/julian-bruyers/svg2icon/internal/icns"
+	"github.com/julian-bruyers/svg2icon/internal/ico"
+)
+
+// Convert converts an SVG file to the icon format(s) selected by outputPath,
+// following the rules of ResolveInputOutputs:
+//   - An existing directory receives <name>.ico and <name>.icns, named after
+//     the SVG
+//   - A path ending with ".ico" or ".icns" (in any case) receives only that
+//     format
+//   - A path ending with ".icon" or without extension receives both formats,
+//     using the path without extension as the base name
+//
+// A path that doesn't exist yet is always treated as a file path.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - outputPath: Output directory or file path
+//
+// Returns ErrInvalidPath or an *UnsupportedExtensionError if outputPath
+// doesn't select a format, or an error if SVG processing or file writing
+// fails. When both formats are written, the errors of both are joined.
+func Convert(svgPath string, outputPath string) error {
+	return ConvertWith(svgPath, outputPath, func(icoPath string, icnsPath string) error {
+		var errs []error
+		if icoPath != "" {
+			errs = append(errs, ico.CreateIco(svgPath, icoPath))
+		}
+		if icnsPath != "" {
+			errs = append(errs, icns.CreateIcns(svgPath, icnsPath))
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// ConvertWith resolves the icon paths for outputPath like Convert and passes
+// them to create, which writes the files. Callers that need their own
+// options, overwrite checks or reporting supply create instead of
+// reimplementing the routing.
+//
+// Parameters:
+//   - inputPath: Path of the source SVG file, empty or "-" for stdin
+//   - outputPath: Output directory or file path
+//   - create: Writes the ICO file to icoPath and the ICNS file to icnsPath,
+//     an empty path marks a format that isn't written
+//
+// Returns the error of create, or ErrInvalidPath or an
+// *UnsupportedExtensionError without calling create if outputPath doesn't
+// select a format.
+func ConvertWith(inputPath string, outputPath string, create func(icoPath string, icnsPath string) error) error {
+	icoPath, icnsPath, err := ResolveInputOutputs(inputPath, outputPath)
+	if err != nil {
+		return err
+	}
+	return create(icoPath, icnsPath)
+}
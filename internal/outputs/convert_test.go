@@ -0,0 +1,65 @@
+package outputs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string // Relative to the output directory, "." for the directory itself
+		files  []string
+		err    error
+	}{
+		{"directory", ".", []string{"logo.icns", "logo.ico"}, nil},
+		{"ico", "app.ico", []string{"app.ico"}, nil},
+		{"upper-case ico", "app.ICO", []string{"app.ico"}, nil},
+		{"icns", "app.icns", []string{"app.icns"}, nil},
+		{"icon", "app.icon", []string{"app.icns", "app.ico"}, nil},
+		{"no extension", "app", []string{"app.icns", "app.ico"}, nil},
+		{"unsupported extension", "app.txt", nil, &UnsupportedExtensionError{}},
+		{"missing directory", "missing/app.ico", nil, ErrInvalidPath},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			svgPath := filepath.Join(t.TempDir(), "logo.svg")
+			svg := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 8 8"><rect width="8" height="8"/></svg>`
+			if err := os.WriteFile(svgPath, []byte(svg), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := Convert(svgPath, filepath.Join(dir, test.output))
+			switch want := test.err.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("Convert: %v", err)
+				}
+			case *UnsupportedExtensionError:
+				if !errors.As(err, &want) {
+					t.Fatalf("got %v, want an *UnsupportedExtensionError", err)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Fatalf("got %v, want %v", err, want)
+				}
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var files []string
+			for _, entry := range entries {
+				files = append(files, entry.Name())
+			}
+			if !slices.Equal(files, test.files) {
+				t.Errorf("wrote %v, want %v", files, test.files)
+			}
+		})
+	}
+}
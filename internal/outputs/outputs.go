@@ -0,0 +1,99 @@
+// Package outputs maps an output path to the ICO and ICNS files written for
+// it, following the same rules as the svg2icon command line.
+package outputs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultName is the base name of the icons written into a directory when
+// there is no input file to name them after, e.g. for SVG read from stdin.
+const DefaultName = "icon"
+
+// ErrInvalidPath is returned for an output path that is neither an existing
+// directory nor a file name inside an existing directory.
+var ErrInvalidPath = errors.New("invalid output path")
+
+// UnsupportedExtensionError is returned for an output file whose extension
+// doesn't select an icon format.
+type UnsupportedExtensionError struct {
+	Extension string
+}
+
+func (e *UnsupportedExtensionError) Error() string {
+	return fmt.Sprintf("unsupported output extension %q, use .ico, .icns, .icon or no extension", e.Extension)
+}
+
+// ResolveOutputs returns the ICO and ICNS paths written for outputPath.
+//
+// The rules are those of the svg2icon command line:
+//   - An existing directory gets both files, named DefaultName.ico and
+//     DefaultName.icns (see ResolveInputOutputs to name them after the input)
+//   - A .ico or .icns file (in any case) gets only that format
+//   - A .icon file or a file without extension gets both formats, with the
+//     extension replaced by .ico and .icns
+//
+// Parameters:
+//   - outputPath: Output directory or file path
+//
+// Returns the ICO and ICNS paths, an empty path for a format that isn't
+// written, ErrInvalidPath if the path can't be written to, or an
+// *UnsupportedExtensionError for any other extension.
+func ResolveOutputs(outputPath string) (icoPath, icnsPath string, err error) {
+	return ResolveInputOutputs("", outputPath)
+}
+
+// ResolveInputOutputs returns the ICO and ICNS paths written for outputPath
+// like ResolveOutputs, but names the files written into a directory after
+// the input file, see BaseName.
+//
+// Parameters:
+//   - inputPath: Path of the source SVG file, empty or "-" for stdin
+//   - outputPath: Output directory or file path
+//
+// Returns the ICO and ICNS paths, an empty path for a format that isn't
+// written, or an error as described for ResolveOutputs.
+func ResolveInputOutputs(inputPath string, outputPath string) (icoPath, icnsPath string, err error) {
+	if info, err := os.Stat(outputPath); err == nil && info.IsDir() {
+		base := filepath.Join(outputPath, BaseName(inputPath))
+		return base + ".ico", base + ".icns", nil
+	}
+	if !validFile(outputPath) {
+		return "", "", ErrInvalidPath
+	}
+
+	extension := filepath.Ext(outputPath)
+	base := strings.TrimSuffix(outputPath, extension)
+	switch strings.ToLower(extension) {
+	case ".ico":
+		return base + ".ico", "", nil
+	case ".icns":
+		return "", base + ".icns", nil
+	case ".icon", "":
+		return base + ".ico", base + ".icns", nil
+	}
+	return "", "", &UnsupportedExtensionError{Extension: extension}
+}
+
+// BaseName returns the name icons derived from inputPath are written under:
+// the file name without its extension, or DefaultName for an empty path or
+// "-" (stdin).
+func BaseName(inputPath string) string {
+	if inputPath == "" || inputPath == "-" {
+		return DefaultName
+	}
+	return strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+}
+
+// validFile reports whether path names a file inside an existing directory.
+func validFile(path string) bool {
+	if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+		return false
+	}
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base)) != ""
+}
@@ -0,0 +1,114 @@
+package png
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/srwiley/oksvg"
+)
+
+// AppIconSize is the pixel size of the App Store marketing icon.
+const AppIconSize = 1024
+
+// maxAppIconCanvasBytes bounds the canvas memory of an App Store icon render.
+// The 1024x1024 canvas itself needs 4 MiB, only large Supersample factors
+// come close to the limit.
+const maxAppIconCanvasBytes = 1 << 30
+
+// ErrCanvasTooLarge is returned when a render would allocate more canvas
+// memory than allowed, instead of running the process out of memory.
+var ErrCanvasTooLarge = errors.New("canvas memory exceeds the limit")
+
+// SvgToAppIconPng converts an SVG file to the 1024x1024 PNG that App Store
+// Connect and Xcode asset catalogs expect as the marketing icon.
+//
+// The artwork keeps its aspect ratio, is centered on the square canvas and
+// flattened onto an opaque white background, since App Store Connect rejects
+// marketing icons with an alpha channel.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//
+// Returns the PNG-encoded image data as bytes, or an error if conversion fails.
+func SvgToAppIconPng(svgPath string) ([]byte, error) {
+	svgFile, err := openSvg(svgPath)
+	if err != nil {
+		return nil, err
+	}
+	defer svgFile.Close()
+
+	icon, err := ParseSvgReader(svgFile)
+	if err != nil {
+		return nil, withPath(svgPath, err)
+	}
+	return RenderAppIconPng(icon)
+}
+
+// RenderAppIconPng rasterizes a parsed SVG icon to the 1024x1024 App Store
+// marketing icon like SvgToAppIconPng.
+//
+// Parameters:
+//   - icon: Parsed SVG icon as returned by ParseSvg
+//
+// Returns the PNG-encoded image data as bytes, or an error if encoding fails.
+func RenderAppIconPng(icon *oksvg.SvgIcon) ([]byte, error) {
+	return RenderAppIconPngOpts(icon, DefaultRenderOptions())
+}
+
+// RenderAppIconPngOpts rasterizes a parsed SVG icon to the 1024x1024 App Store
+// marketing icon using the given render options. The aspect ratio is always
+// preserved and the icon is always encoded with 8 bits per channel.
+//
+// The artwork is rendered onto a transparent canvas, so RejectBlank still
+// detects an SVG without visible content, and then flattened onto
+// opts.Background, or white if it is nil.
+//
+// Parameters:
+//   - icon: Parsed SVG icon as returned by ParseSvg
+//   - opts: Options controlling the rasterization
+//
+// Returns the PNG-encoded image data as bytes, or an error if the background
+// isn't opaque, the render would need more canvas memory than allowed
+// (wrapping ErrCanvasTooLarge), or rendering or encoding fails.
+func RenderAppIconPngOpts(icon *oksvg.SvgIcon, opts RenderOptions) ([]byte, error) {
+	background := opts.Background
+	if background == nil {
+		background = color.White
+	}
+	if _, _, _, alpha := background.RGBA(); alpha != 0xffff {
+		return nil, errors.New("invalid app icon background: must be opaque")
+	}
+
+	opts = opts.forSize(AppIconSize)
+	opts.PreserveAspectRatio = true
+	opts.Background = nil
+	opts.BitDepth = 8
+	if needed := estimateCanvasBytes(AppIconSize, opts); needed > maxAppIconCanvasBytes {
+		return nil, fmt.Errorf("%w: rendering the %dx%d app icon needs about %s of canvases, more than %s; lower Supersample",
+			ErrCanvasTooLarge, AppIconSize, AppIconSize, formatBytes(int(min(needed, 1<<62))), formatBytes(maxAppIconCanvasBytes))
+	}
+
+	postProcess := opts.PostProcess
+	opts.PostProcess = func(size int, img *image.RGBA) error {
+		if postProcess != nil {
+			if err := postProcess(size, img); err != nil {
+				return err
+			}
+		}
+		flatten(img, background)
+		return nil
+	}
+	return RenderIconOpts(icon, AppIconSize, opts)
+}
+
+// flatten composites img over the opaque background color in place, which
+// leaves every pixel fully opaque.
+func flatten(img *image.RGBA, background color.Color) {
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	copy(img.Pix, flat.Pix)
+}
@@ -0,0 +1,92 @@
+package png
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestRenderAppIconPngOpaque(t *testing.T) {
+	// A circle leaves the corners of the canvas transparent
+	icon := parseTest(t, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 32">
+<circle cx="32" cy="16" r="12" fill="#2b6cb0"/>
+</svg>`)
+
+	tests := []struct {
+		name       string
+		background color.Color
+		corner     color.NRGBA
+	}{
+		{"default", nil, color.NRGBA{0xff, 0xff, 0xff, 0xff}},
+		{"background", color.NRGBA{0x10, 0x20, 0x30, 0xff}, color.NRGBA{0x10, 0x20, 0x30, 0xff}},
+	}
+	for _, test := range tests {
+		opts := DefaultRenderOptions()
+		opts.Background = test.background
+		opts.RejectBlank = true
+		data, err := RenderAppIconPngOpts(icon, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Color type 2 is truecolor without an alpha channel
+		if colorType := data[25]; colorType != 2 {
+			t.Errorf("%s: PNG color type %d, want 2", test.name, colorType)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds() != image.Rect(0, 0, AppIconSize, AppIconSize) {
+			t.Fatalf("%s: bounds %v, want %dx%d", test.name, img.Bounds(), AppIconSize, AppIconSize)
+		}
+		if got := color.NRGBAModel.Convert(img.At(0, 0)); got != test.corner {
+			t.Errorf("%s: corner %v, want %v", test.name, got, test.corner)
+		}
+		center := color.NRGBAModel.Convert(img.At(AppIconSize/2, AppIconSize/2))
+		if want := (color.NRGBA{0x2b, 0x6c, 0xb0, 0xff}); center != want {
+			t.Errorf("%s: center %v, want %v", test.name, center, want)
+		}
+	}
+}
+
+func TestRenderAppIconPngErrors(t *testing.T) {
+	icon := parseTest(t, triangleSvg)
+	blankIcon := parseTest(t, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64"><g/></svg>`)
+
+	tests := []struct {
+		name   string
+		opts   func() RenderOptions
+		blank  bool // Render the blank SVG
+		target error
+	}{
+		{"blank", func() RenderOptions {
+			opts := DefaultRenderOptions()
+			opts.RejectBlank = true
+			return opts
+		}, true, ErrBlankRender},
+		{"huge supersample", func() RenderOptions {
+			opts := DefaultRenderOptions()
+			opts.Supersample = 64
+			return opts
+		}, false, ErrCanvasTooLarge},
+	}
+	for _, test := range tests {
+		rendered := icon
+		if test.blank {
+			rendered = blankIcon
+		}
+		if _, err := RenderAppIconPngOpts(rendered, test.opts()); !errors.Is(err, test.target) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.target)
+		}
+	}
+
+	opts := DefaultRenderOptions()
+	opts.Background = color.NRGBA{0xff, 0xff, 0xff, 0x80}
+	if _, err := RenderAppIconPngOpts(icon, opts); err == nil {
+		t.Error("a translucent background was accepted")
+	}
+}
@@ -0,0 +1,199 @@
+package png
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/srwiley/oksvg"
+)
+
+// cacheVersion is part of every cache key. Bump it whenever a change to the
+// rasterizer alters the output for the same SVG and options, so entries
+// written by older versions are never served.
+const cacheVersion = 1
+
+// DefaultCacheDir returns the directory used for cached renders, svg2icon
+// inside the user's cache directory.
+//
+// Returns an error if the user's cache directory can't be determined.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "svg2icon"), nil
+}
+
+// HashSvg returns the content hash that identifies SVG markup in cache keys.
+func HashSvg(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseSvgHash reads and parses an SVG file like ParseSvg and also returns the
+// content hash of the markup it parsed, for use with RenderIconCached.
+//
+// Returns the parsed icon and its hash, or an error if the file can't be read
+// or parsed.
+func ParseSvgHash(svgPath string) (*oksvg.SvgIcon, string, error) {
+	return ParseSvgChecked(svgPath, false, nil)
+}
+
+// ParseSvgReaderHash parses SVG markup read from r like ParseSvgReader and
+// also returns the content hash of the markup, for use with RenderIconCached.
+//
+// Returns the parsed icon and its hash, or an error if the markup can't be
+// read or parsed.
+func ParseSvgReaderHash(r io.Reader) (*oksvg.SvgIcon, string, error) {
+	return ParseSvgReaderChecked(r, false, nil)
+}
+
+// ParseSvgChecked reads and parses an SVG file like ParseSvgHash and checks
+// the markup for unsupported features with CheckFeatures first. The file is
+// read once, so the markup that is checked is exactly the markup that is
+// parsed and rendered.
+//
+// Parameters:
+//   - svgPath: Path to the SVG file
+//   - strict: Reject unsupported features instead of logging them
+//   - logger: Receives one message per feature when not strict, may be nil
+//
+// Returns the parsed icon and the content hash of its markup, or an error if
+// the file can't be read or parsed or strict is set and features were found.
+func ParseSvgChecked(svgPath string, strict bool, logger Logger) (*oksvg.SvgIcon, string, error) {
+	data, err := ReadSvg(svgPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	icon, err := parseChecked(data, strict, logger)
+	if err != nil {
+		return nil, "", withPath(svgPath, err)
+	}
+	return icon, HashSvg(data), nil
+}
+
+// ParseSvgReaderChecked parses SVG markup read from r like ParseSvgChecked.
+//
+// Returns the parsed icon and the content hash of its markup, or an error if
+// the markup can't be read or parsed or strict is set and features were
+// found.
+func ParseSvgReaderChecked(r io.Reader, strict bool, logger Logger) (*oksvg.SvgIcon, string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+
+	icon, err := parseChecked(data, strict, logger)
+	if err != nil {
+		return nil, "", err
+	}
+	return icon, HashSvg(data), nil
+}
+
+// parseChecked checks the markup with CheckFeatures when strict is set or a
+// logger is given, then parses it.
+func parseChecked(data []byte, strict bool, logger Logger) (*oksvg.SvgIcon, error) {
+	if strict || logger != nil {
+		if err := CheckFeatures(data, strict, logger); err != nil {
+			return nil, err
+		}
+	}
+	return ParseSvgReader(bytes.NewReader(data))
+}
+
+// RenderIconCached renders a parsed SVG icon like RenderIconOpts, reusing a
+// PNG from cacheDir when the same markup was rendered at the same size with
+// the same options before.
+//
+// Entries are addressed purely by content: the key covers svgHash, the pixel
+// size and every option that affects the output, so a changed SVG or option
+// simply misses the cache. A cache that can't be read or written is skipped
+// and the icon is rendered normally.
+//
+// Parameters:
+//   - icon: Parsed SVG icon as returned by ParseSvgHash
+//   - svgHash: Content hash of the markup the icon was parsed from, see HashSvg
+//   - pxSize: Output dimensions in pixels (width and height)
+//   - opts: Options controlling the rasterization
+//   - cacheDir: Cache directory, an empty cacheDir or svgHash or a set
+//     opts.PostProcess disables caching
+//
+// Returns the PNG-encoded image data as bytes, or an error if rendering fails.
+func RenderIconCached(icon *oksvg.SvgIcon, svgHash string, pxSize int, opts RenderOptions, cacheDir string) ([]byte, error) {
+	opts = opts.forSize(pxSize)
+	if cacheDir == "" || svgHash == "" || opts.PostProcess != nil {
+		return RenderIconOpts(icon, pxSize, opts)
+	}
+
+	path := filepath.Join(cacheDir, cacheKey(svgHash, pxSize, opts)+".png")
+	if data, err := os.ReadFile(path); err == nil {
+		if opts.Logger != nil {
+			opts.Logger.Logf("reused cached %dx%d", pxSize, pxSize)
+		}
+		return data, nil
+	}
+
+	data, err := RenderIconOpts(icon, pxSize, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeCache(cacheDir, path, data); err != nil && opts.Logger != nil {
+		opts.Logger.Logf("caching %dx%d failed: %s", pxSize, pxSize, err)
+	}
+	return data, nil
+}
+
+// cacheKey returns the cache key of a render. Every RenderOptions field that
+// affects the encoded PNG must be part of the key.
+func cacheKey(svgHash string, pxSize int, opts RenderOptions) string {
+	viewBox := "none"
+	if opts.ViewBox != nil {
+		viewBox = fmt.Sprintf("%g,%g,%g,%g", opts.ViewBox.MinX, opts.ViewBox.MinY, opts.ViewBox.W, opts.ViewBox.H)
+	}
+
+	key := fmt.Sprintf("v%d|%s|%d|aspect=%t|align=%d|bg=%s|ss=%d|gamma=%t|blank=%t|aa=%t|vb=%s|trim=%t|level=%d|strokes=%t|tint=%s|padding=%g|corners=%g|profile=%d|premul=%t|clip=%t|depth=%d",
+		cacheVersion, svgHash, pxSize,
+		opts.PreserveAspectRatio, opts.Align, colorKey(opts.Background), opts.Supersample, opts.GammaCorrect, opts.RejectBlank, opts.Antialias,
+		viewBox, opts.AutoTrim, opts.CompressionLevel, opts.NormalizeStrokes, colorKey(opts.Tint), opts.Padding, opts.RoundedCorners, opts.ColorProfile, opts.PremultipliedAlpha, opts.ClipToViewBox, max(opts.BitDepth, 8))
+	return HashSvg([]byte(key))
+}
+
+// colorKey formats an optional color for cache keys.
+func colorKey(c color.Color) string {
+	if c == nil {
+		return "none"
+	}
+	rgba := color.RGBA64Model.Convert(c).(color.RGBA64)
+	return fmt.Sprintf("%04x%04x%04x%04x", rgba.R, rgba.G, rgba.B, rgba.A)
+}
+
+// writeCache stores data at path inside cacheDir. The data is written to a
+// temporary file first and renamed into place, so concurrent readers never
+// see a partially written entry.
+func writeCache(cacheDir string, path string, data []byte) error {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(cacheDir, "render-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,77 @@
+package png
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// ColorProfile selects the color-space information embedded in encoded PNGs.
+type ColorProfile int
+
+// The color profiles accepted by RenderOptions.
+const (
+	// ColorProfileSRGB marks the image as sRGB with an sRGB chunk, plus the
+	// gAMA and cHRM chunks the PNG specification recommends alongside it
+	// for decoders that don't understand sRGB.
+	ColorProfileSRGB ColorProfile = iota
+	// ColorProfileNone writes no color-space chunks, leaving the
+	// interpretation of the colors to the viewer.
+	ColorProfileNone
+)
+
+// pngSignatureLen and ihdrChunkLen are the sizes of the PNG signature and of
+// the IHDR chunk that always follows it, including length, type and CRC.
+const (
+	pngSignatureLen = 8
+	ihdrChunkLen    = 4 + 4 + 13 + 4
+)
+
+// srgbChunks are the chunks embedded for ColorProfileSRGB: sRGB with the
+// perceptual rendering intent, the sRGB gamma of 1/2.2 and the sRGB
+// primaries and D65 white point, both scaled by 100000.
+var srgbChunks = append(append(
+	pngChunk("sRGB", []byte{0}),
+	pngChunk("gAMA", uint32Bytes(45455))...),
+	pngChunk("cHRM", uint32Bytes(31270, 32900, 64000, 33000, 30000, 60000, 15000, 6000))...)
+
+// injectColorProfile splices the chunks of the given profile into the
+// PNG-encoded data, directly after the IHDR chunk where the specification
+// requires color-space chunks to precede PLTE and IDAT.
+func injectColorProfile(data []byte, profile ColorProfile) ([]byte, error) {
+	switch profile {
+	case ColorProfileNone:
+		return data, nil
+	case ColorProfileSRGB:
+	default:
+		return nil, fmt.Errorf("unknown color profile %d", profile)
+	}
+
+	at := pngSignatureLen + ihdrChunkLen
+	if len(data) < at || string(data[pngSignatureLen+4:pngSignatureLen+8]) != "IHDR" {
+		return nil, fmt.Errorf("png data doesn't start with an IHDR chunk")
+	}
+
+	result := make([]byte, 0, len(data)+len(srgbChunks))
+	result = append(result, data[:at]...)
+	result = append(result, srgbChunks...)
+	return append(result, data[at:]...), nil
+}
+
+// pngChunk returns a complete PNG chunk: the big-endian data length, the
+// chunk type, the data and the CRC-32 of type and data.
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// uint32Bytes encodes the values as consecutive big-endian uint32s.
+func uint32Bytes(values ...uint32) []byte {
+	var data []byte
+	for _, value := range values {
+		data = binary.BigEndian.AppendUint32(data, value)
+	}
+	return data
+}
@@ -0,0 +1,45 @@
+package png
+
+import (
+	"context"
+)
+
+// RenderContext runs render in its own goroutine and waits until it finishes
+// or ctx is done, whichever happens first.
+//
+// rasterx can't interrupt a render that already started, so when ctx is done
+// first the render keeps running in the background and its result is dropped.
+// Renders draw onto a copy of the parsed icon and their own canvases, so the
+// abandoned render never touches the caller's data or files; it only keeps
+// its CPU time and memory until it finishes.
+//
+// Parameters:
+//   - ctx: Context whose cancellation stops waiting for the render
+//   - render: Function rendering the image, typically a closure over
+//     RenderIconOpts or RasterizeIcon
+//
+// Returns the result of render, or the context error if ctx is done before
+// render finishes. render isn't started if ctx is already done.
+func RenderContext(ctx context.Context, render func() ([]byte, error)) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	// Buffered, so an abandoned render can always deliver its result and exit
+	done := make(chan result, 1)
+	go func() {
+		data, err := render()
+		done <- result{data, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,60 @@
+package png
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// roundCorners clears the parts of img outside a rounded rectangle with the
+// given corner radius in pixels. Pixels on the edge of the arcs are faded by
+// their approximate coverage, or kept or cleared entirely when antialias is
+// off.
+// img is an *image.RGBA or an *image.RGBA64.
+func roundCorners(img draw.Image, radius float64, antialias bool) {
+	bounds := img.Bounds()
+	width, height := float64(bounds.Dx()), float64(bounds.Dy())
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// Distance of the pixel center beyond the nearest arc center,
+			// which is zero outside the corner regions
+			px := float64(x-bounds.Min.X) + 0.5
+			py := float64(y-bounds.Min.Y) + 0.5
+			dx := math.Max(math.Max(radius-px, px-(width-radius)), 0)
+			dy := math.Max(math.Max(radius-py, py-(height-radius)), 0)
+			if dx == 0 || dy == 0 {
+				continue
+			}
+
+			coverage := math.Min(math.Max(radius-math.Hypot(dx, dy)+0.5, 0), 1)
+			if !antialias {
+				coverage = math.Round(coverage)
+			}
+			if coverage == 1 {
+				continue
+			}
+
+			fade(img, x, y, coverage)
+		}
+	}
+}
+
+// fade scales the pixel of img at x, y by coverage. The canvas is
+// premultiplied, so every channel is scaled.
+func fade(img draw.Image, x int, y int, coverage float64) {
+	switch img := img.(type) {
+	case *image.RGBA:
+		i := img.PixOffset(x, y)
+		for c := 0; c < 4; c++ {
+			img.Pix[i+c] = uint8(float64(img.Pix[i+c])*coverage + 0.5)
+		}
+	case *image.RGBA64:
+		pixel := img.RGBA64At(x, y)
+		scale := func(value uint16) uint16 {
+			return uint16(float64(value)*coverage + 0.5)
+		}
+		img.SetRGBA64(x, y, color.RGBA64{R: scale(pixel.R), G: scale(pixel.G), B: scale(pixel.B), A: scale(pixel.A)})
+	}
+}
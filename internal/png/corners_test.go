@@ -0,0 +1,58 @@
+package png
+
+import (
+	"image"
+	"testing"
+)
+
+// squareSvg fills its whole viewBox.
+const squareSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+<rect width="10" height="10" fill="#36c"/>
+</svg>`
+
+func TestRoundedCorners(t *testing.T) {
+	const size = 64
+
+	tests := []struct {
+		radius      float64
+		transparent []image.Point
+		opaque      []image.Point
+	}{
+		{
+			radius: 0,
+			opaque: []image.Point{{0, 0}, {size - 1, 0}, {0, size - 1}, {size - 1, size - 1}},
+		},
+		{
+			radius:      0.2,
+			transparent: []image.Point{{0, 0}, {2, 2}, {size - 1, 0}, {size - 3, 2}, {0, size - 1}, {size - 1, size - 1}},
+			opaque:      []image.Point{{size / 2, 0}, {0, size / 2}, {size - 1, size / 2}, {size / 2, size - 1}, {12, 12}, {size / 2, size / 2}},
+		},
+		{
+			radius:      0.5,
+			transparent: []image.Point{{0, 0}, {8, 8}, {size - 1, 0}, {0, size - 1}, {size - 9, size - 9}},
+			opaque:      []image.Point{{size / 2, 1}, {1, size / 2}, {size / 2, size / 2}, {12, 12}},
+		},
+	}
+	for _, test := range tests {
+		for _, bitDepth := range []int{8, 16} {
+			opts := DefaultRenderOptions()
+			opts.RoundedCorners = test.radius
+
+			icon := parseTest(t, squareSvg)
+			canvas, err := rasterizeIcon(icon, size, opts, bitDepth == 16)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, point := range test.transparent {
+				if _, _, _, alpha := canvas.At(point.X, point.Y).RGBA(); alpha != 0 {
+					t.Errorf("radius %v, %d bits: pixel %v has alpha %#x, want 0", test.radius, bitDepth, point, alpha)
+				}
+			}
+			for _, point := range test.opaque {
+				if _, _, _, alpha := canvas.At(point.X, point.Y).RGBA(); alpha != 0xffff {
+					t.Errorf("radius %v, %d bits: pixel %v has alpha %#x, want 0xffff", test.radius, bitDepth, point, alpha)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package png_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/julian-bruyers/svg2icon/internal/ico"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+)
+
+// gradientSvg uses a gradient and semi-transparent edges, which exercise
+// every PNG filter type.
+const gradientSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">
+<defs><linearGradient id="g" x2="1" y2="1"><stop offset="0" stop-color="#f60"/><stop offset="1" stop-color="#06f"/></linearGradient></defs>
+<circle cx="32" cy="32" r="28" fill="url(#g)" fill-opacity="0.8"/>
+</svg>
+`
+
+// pngChunkTypes returns the chunk types of PNG data in file order, failing
+// the test on a malformed chunk or a wrong CRC.
+func pngChunkTypes(t *testing.T, data []byte) []string {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) {
+		t.Fatal("missing PNG signature")
+	}
+
+	var types []string
+	for rest := data[8:]; len(rest) > 0; {
+		if len(rest) < 12 {
+			t.Fatalf("truncated chunk after %v", types)
+		}
+		length := int(binary.BigEndian.Uint32(rest))
+		if len(rest) < 12+length {
+			t.Fatalf("chunk %q extends past the end", rest[4:8])
+		}
+		chunk := rest[4 : 8+length]
+		if crc := binary.BigEndian.Uint32(rest[8+length:]); crc != crc32.ChecksumIEEE(chunk) {
+			t.Fatalf("chunk %q has a wrong CRC", chunk[:4])
+		}
+		types = append(types, string(chunk[:4]))
+		rest = rest[12+length:]
+	}
+	return types
+}
+
+func TestOutputIsDeterministic(t *testing.T) {
+	svgPath := filepath.Join(t.TempDir(), "gradient.svg")
+	if err := os.WriteFile(svgPath, []byte(gradientSvg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		encode func() ([]byte, error)
+	}{
+		{"SvgToPng 16px", func() ([]byte, error) { return png.SvgToPng(svgPath, 16) }},
+		{"SvgToPng 256px", func() ([]byte, error) { return png.SvgToPng(svgPath, 256) }},
+		{"EncodeIco", func() ([]byte, error) { return ico.EncodeIco(svgPath, ico.IconSizes) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			first, err := test.encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := test.encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(first, second) {
+				t.Error("encoding the same SVG twice gave different bytes")
+			}
+		})
+	}
+}
+
+func TestSvgToPngMatchesReader(t *testing.T) {
+	svgPath := filepath.Join(t.TempDir(), "gradient.svg")
+	if err := os.WriteFile(svgPath, []byte(gradientSvg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	icon, err := png.ParseSvg(svgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{16, 48, 256} {
+		fromFile, err := png.SvgToPng(svgPath, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromReader, err := png.SvgToPngReader(strings.NewReader(gradientSvg), size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsedOnce, err := png.RenderIcon(icon, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(fromFile, fromReader) {
+			t.Errorf("%dpx: SvgToPng and SvgToPngReader gave different bytes", size)
+		}
+		if !bytes.Equal(fromFile, parsedOnce) {
+			t.Errorf("%dpx: SvgToPng and ParseSvg with RenderIcon gave different bytes", size)
+		}
+	}
+
+	// Rejected markup names the file like ParseSvg
+	notSvg := filepath.Join(t.TempDir(), "notes.svg")
+	if err := os.WriteFile(notSvg, []byte("plain text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = png.SvgToPng(notSvg, 16)
+	if !errors.Is(err, png.ErrNotSvg) || !strings.HasPrefix(err.Error(), notSvg+": ") {
+		t.Errorf("got %v, want ErrNotSvg naming %s", err, notSvg)
+	}
+}
+
+func TestNoTimeChunk(t *testing.T) {
+	svgPath := filepath.Join(t.TempDir(), "gradient.svg")
+	if err := os.WriteFile(svgPath, []byte(gradientSvg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every PNG embedded in the ICO as well as a plain PNG
+	var images [][]byte
+	data, err := ico.EncodeIco(svgPath, ico.IconSizes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ico.ReadIco(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		images = append(images, data[entry.ImageOffset:entry.ImageOffset+entry.BytesInRes])
+	}
+	single, err := png.SvgToPng(svgPath, 48)
+	if err != nil {
+		t.Fatal(err)
+	}
+	images = append(images, single)
+
+	for i, image := range images {
+		types := pngChunkTypes(t, image)
+		for _, chunkType := range types {
+			if chunkType == "tIME" {
+				t.Errorf("image %d has a tIME chunk: %v", i, types)
+			}
+		}
+		if len(types) < 2 || types[0] != "IHDR" || types[1] != "sRGB" {
+			t.Errorf("image %d: want IHDR followed by sRGB, got %v", i, types)
+		}
+	}
+}
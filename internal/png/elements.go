@@ -0,0 +1,201 @@
+package png
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// ErrUnsupportedElement is returned when an SVG contains an element whose
+// content can't be rendered, which would otherwise silently be left out of
+// the icon.
+var ErrUnsupportedElement = errors.New("unsupported SVG element")
+
+// ErrUnsupportedFeature is returned by CheckFeatures in strict mode when an
+// SVG uses features the rasterizer ignores, see DetectFeatures.
+var ErrUnsupportedFeature = errors.New("unsupported SVG feature")
+
+// unsupportedElements are the SVG elements the rasterizer skips entirely.
+// Embedded or linked raster images can't be drawn by oksvg.
+var unsupportedElements = map[string]bool{
+	"image": true,
+}
+
+// checkElements scans the SVG markup and returns ErrUnsupportedElement naming
+// the first element in unsupportedElements. Markup that isn't well-formed
+// is left for the SVG parser to report.
+func checkElements(data []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil
+		}
+		if element, ok := token.(xml.StartElement); ok && unsupportedElements[element.Name.Local] {
+			return fmt.Errorf("%w <%s>", ErrUnsupportedElement, element.Name.Local)
+		}
+	}
+}
+
+// readChecked reads the complete SVG markup from r and checks it for
+// unsupported elements.
+func readChecked(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkElements(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Feature is an SVG feature found in the markup that the rasterizer ignores,
+// so the rendered icon differs from what a browser shows.
+type Feature struct {
+	Element string // Element name, e.g. "text"
+	Count   int    // Number of occurrences
+	Effect  string // What happens to the element when rendering
+}
+
+// String describes the feature, e.g. "<text> (2x): text is not drawn".
+func (f Feature) String() string {
+	return fmt.Sprintf("<%s> (%dx): %s", f.Element, f.Count, f.Effect)
+}
+
+// ignoredElements are the SVG elements the rasterizer skips without an
+// error, mapped to the effect on the rendered icon.
+var ignoredElements = map[string]string{
+	"filter":        "filter effects such as blurs and shadows are not applied",
+	"text":          "text is not drawn",
+	"textPath":      "text is not drawn",
+	"mask":          "masks are not applied",
+	"clipPath":      "clipping paths are not applied",
+	"pattern":       "pattern fills are not drawn",
+	"marker":        "markers are not drawn",
+	"foreignObject": "foreign content is not drawn",
+}
+
+// useOutsideDefs is the effect of a <use> referencing an element that isn't
+// defined inside <defs>, the only place references are resolved from.
+const useOutsideDefs = "references to elements outside <defs> are not drawn"
+
+// DetectFeatures scans the SVG markup for features the rasterizer ignores:
+// the elements in ignoredElements and <use> elements referencing anything
+// but an element inside <defs>.
+//
+// oksvg skips these silently, which leaves icons partially or entirely
+// blank. The result lets callers warn about it before converting.
+//
+// Parameters:
+//   - data: SVG markup
+//
+// Returns the features in order of their first occurrence, with a <use>
+// entry last, or nil if the markup only uses supported features. Markup
+// that isn't well-formed is scanned up to the first error.
+func DetectFeatures(data []byte) []Feature {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	var features []Feature
+	found := map[string]int{} // Index of each element in features
+	defsIDs := map[string]bool{}
+	var useTargets []string
+	defsDepth := 0 // Nesting depth inside <defs>, 0 outside
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			name := element.Name.Local
+			if defsDepth > 0 {
+				defsDepth++
+				if id := attrValue(element, "id"); id != "" {
+					defsIDs[id] = true
+				}
+			}
+			switch {
+			case name == "defs":
+				defsDepth = max(defsDepth, 1)
+			case name == "use":
+				useTargets = append(useTargets, useHref(element))
+			case ignoredElements[name] != "":
+				if i, ok := found[name]; ok {
+					features[i].Count++
+					continue
+				}
+				found[name] = len(features)
+				features = append(features, Feature{Element: name, Count: 1, Effect: ignoredElements[name]})
+			}
+		case xml.EndElement:
+			if defsDepth > 0 {
+				defsDepth--
+			}
+		}
+	}
+
+	unresolved := 0
+	for _, target := range useTargets {
+		if !defsIDs[strings.TrimPrefix(target, "#")] {
+			unresolved++
+		}
+	}
+	if unresolved > 0 {
+		features = append(features, Feature{Element: "use", Count: unresolved, Effect: useOutsideDefs})
+	}
+	return features
+}
+
+// useHref returns the reference of a <use> element from its href attribute,
+// or the older xlink:href.
+func useHref(element xml.StartElement) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == "href" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// CheckFeatures reports the features DetectFeatures finds in the SVG markup.
+//
+// Strict callers such as CI pipelines get an error instead of an icon that
+// silently differs from the SVG. Otherwise every feature is logged and the
+// conversion can proceed.
+//
+// Parameters:
+//   - data: SVG markup
+//   - strict: Return the features as an error instead of logging them
+//   - logger: Receives one message per feature when not strict, may be nil
+//
+// Returns an error wrapping ErrUnsupportedFeature that lists the features
+// when strict is set and any were found, or nil.
+func CheckFeatures(data []byte, strict bool, logger Logger) error {
+	features := DetectFeatures(data)
+	if len(features) == 0 {
+		return nil
+	}
+
+	if strict {
+		list := make([]string, len(features))
+		for i, feature := range features {
+			list[i] = feature.String()
+		}
+		return fmt.Errorf("%w: %s", ErrUnsupportedFeature, strings.Join(list, "; "))
+	}
+	if logger != nil {
+		for _, feature := range features {
+			logger.Logf("%s: %s", ErrUnsupportedFeature, feature)
+		}
+	}
+	return nil
+}
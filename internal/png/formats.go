@@ -0,0 +1,104 @@
+package png
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+
+	"github.com/srwiley/oksvg"
+	"golang.org/x/image/bmp"
+)
+
+// gifPalette holds a fully transparent entry followed by the first 255 colors
+// of the Plan 9 palette, so transparent areas survive the quantization.
+var gifPalette = append(color.Palette{color.Transparent}, palette.Plan9[:255]...)
+
+// SvgToBmp converts an SVG file to a 32-bit BMP image at the specified pixel size.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - pxSize: Output dimensions in pixels (width and height)
+//
+// Returns the BMP-encoded image data as bytes, or an error if conversion fails.
+func SvgToBmp(svgPath string, pxSize int) ([]byte, error) {
+	icon, err := ParseSvg(svgPath)
+	if err != nil {
+		return nil, err
+	}
+	return RenderIconBmp(icon, pxSize)
+}
+
+// SvgToGif converts an SVG file to a GIF image at the specified pixel size.
+//
+// GIF images are limited to 256 colors, so the render is quantized to a fixed
+// palette with Floyd-Steinberg dithering. Fully transparent pixels stay
+// transparent, partially transparent edges are dithered.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - pxSize: Output dimensions in pixels (width and height)
+//
+// Returns the GIF-encoded image data as bytes, or an error if conversion fails.
+func SvgToGif(svgPath string, pxSize int) ([]byte, error) {
+	icon, err := ParseSvg(svgPath)
+	if err != nil {
+		return nil, err
+	}
+	return RenderIconGif(icon, pxSize)
+}
+
+// RenderIconBmp rasterizes a parsed SVG icon to a 32-bit BMP image at the
+// specified pixel size.
+//
+// Returns the BMP-encoded image data as bytes, or an error if encoding fails.
+func RenderIconBmp(icon *oksvg.SvgIcon, pxSize int) ([]byte, error) {
+	return RenderIconBmpOpts(icon, pxSize, DefaultRenderOptions())
+}
+
+// RenderIconBmpOpts rasterizes a parsed SVG icon to a 32-bit BMP image at the
+// specified pixel size using the given render options.
+//
+// Returns the BMP-encoded image data as bytes, or an error if encoding fails.
+func RenderIconBmpOpts(icon *oksvg.SvgIcon, pxSize int, opts RenderOptions) ([]byte, error) {
+	canvas, err := RasterizeIcon(icon, pxSize, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	if err := bmp.Encode(&buffer, canvas); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// RenderIconGif rasterizes a parsed SVG icon to a GIF image at the specified
+// pixel size, quantized like SvgToGif.
+//
+// Returns the GIF-encoded image data as bytes, or an error if encoding fails.
+func RenderIconGif(icon *oksvg.SvgIcon, pxSize int) ([]byte, error) {
+	return RenderIconGifOpts(icon, pxSize, DefaultRenderOptions())
+}
+
+// RenderIconGifOpts rasterizes a parsed SVG icon to a GIF image at the
+// specified pixel size using the given render options.
+//
+// Returns the GIF-encoded image data as bytes, or an error if encoding fails.
+func RenderIconGifOpts(icon *oksvg.SvgIcon, pxSize int, opts RenderOptions) ([]byte, error) {
+	canvas, err := RasterizeIcon(icon, pxSize, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	paletted := image.NewPaletted(canvas.Bounds(), gifPalette)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), canvas, image.Point{})
+
+	var buffer bytes.Buffer
+	if err := gif.Encode(&buffer, paletted, nil); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
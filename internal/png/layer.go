@@ -0,0 +1,131 @@
+package png
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/srwiley/oksvg"
+)
+
+// ErrLayerNotFound is returned when an SVG has no top-level group with the
+// requested layer id.
+var ErrLayerNotFound = errors.New("layer not found")
+
+// layerShared are the top-level elements kept when a layer is selected, since
+// they only define resources the selected group may reference.
+var layerShared = map[string]bool{
+	"defs":           true,
+	"style":          true,
+	"title":          true,
+	"desc":           true,
+	"metadata":       true,
+	"linearGradient": true,
+	"radialGradient": true,
+	"clipPath":       true,
+	"mask":           true,
+	"pattern":        true,
+	"symbol":         true,
+	"marker":         true,
+	"filter":         true,
+}
+
+// SelectLayer returns the SVG markup with every drawable top-level element
+// removed except the <g> group whose id is id.
+//
+// This renders a single variant of a master SVG that holds several icons in
+// separate groups. Resource elements such as <defs> and gradients are kept.
+// The remaining markup is copied byte for byte, so namespaces and formatting
+// are preserved.
+//
+// Parameters:
+//   - data: SVG markup, encoded as UTF-8
+//   - id: Id of the top-level group to keep
+//
+// Returns the filtered markup, ErrLayerNotFound if no top-level group has the
+// id, or an error if the markup isn't well-formed.
+func SelectLayer(data []byte, id string) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	var out bytes.Buffer
+	copied := int64(0) // Everything before this offset has been handled
+	dropStart := int64(-1)
+	depth := 0
+	found := false
+	for {
+		start := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			depth++
+			// Depth 1 is the root <svg>, its children are the top-level elements
+			if depth != 2 {
+				continue
+			}
+			if token.Name.Local == "g" && attrValue(token, "id") == id {
+				found = true
+			} else if !layerShared[token.Name.Local] {
+				dropStart = start
+			}
+		case xml.EndElement:
+			if depth == 2 && dropStart >= 0 {
+				out.Write(data[copied:dropStart])
+				copied = decoder.InputOffset()
+				dropStart = -1
+			}
+			depth--
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%w: no top-level group with id %q", ErrLayerNotFound, id)
+	}
+	out.Write(data[copied:])
+	return out.Bytes(), nil
+}
+
+// attrValue returns the value of the unqualified attribute name, or "".
+func attrValue(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Space == "" && attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// ParseSvgLayer reads an SVG file and parses only the top-level group with the
+// given id, as selected by SelectLayer.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - id: Id of the top-level group to render
+//
+// Returns the parsed icon, or an error if the file can't be read or parsed or
+// has no such group.
+func ParseSvgLayer(svgPath string, id string) (*oksvg.SvgIcon, error) {
+	data, err := ReadSvg(svgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	layer, err := SelectLayer(data, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", svgPath, err)
+	}
+
+	icon, err := ParseSvgReader(bytes.NewReader(layer))
+	if err != nil {
+		return nil, withPath(svgPath, err)
+	}
+	return icon, nil
+}
@@ -0,0 +1,78 @@
+package png
+
+import (
+	"bytes"
+	"encoding/xml"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// pixelsPerUnit converts the absolute CSS length units to pixels at 96 DPI.
+// The empty unit is a plain number, which SVG treats as pixels.
+var pixelsPerUnit = map[string]float64{
+	"":   1,
+	"px": 1,
+	"pt": 96.0 / 72,
+	"pc": 16,
+	"in": 96,
+	"cm": 96 / 2.54,
+	"mm": 96 / 25.4,
+}
+
+// NaturalSize returns the pixel size the SVG declares for itself with the
+// width and height attributes of its root element, the larger of the two
+// rounded up.
+//
+// Sizes rendered above the natural size add no detail to rasters embedded in
+// the SVG. A purely vector SVG still scales cleanly to any size.
+//
+// Parameters:
+//   - data: SVG markup
+//
+// Returns the natural size, or false if the root element doesn't declare
+// both a width and a height in absolute units (e.g. a percentage or only a
+// viewBox) or the markup can't be read.
+func NaturalSize(data []byte) (int, bool) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return 0, false
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if element.Name.Local != "svg" {
+			return 0, false
+		}
+
+		width, okWidth := parseLength(attrValue(element, "width"))
+		height, okHeight := parseLength(attrValue(element, "height"))
+		if !okWidth || !okHeight {
+			return 0, false
+		}
+		return int(math.Ceil(math.Max(width, height))), true
+	}
+}
+
+// parseLength parses an SVG length in absolute units such as "48", "48px" or
+// "0.5in" into pixels. Returns false for relative units and invalid or
+// non-positive lengths.
+func parseLength(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	number := strings.TrimRight(value, "abcdefghijklmnopqrstuvwxyz%")
+	scale, ok := pixelsPerUnit[value[len(number):]]
+	if !ok {
+		return 0, false
+	}
+	length, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || length <= 0 || math.IsInf(length, 0) {
+		return 0, false
+	}
+	return length * scale, true
+}
@@ -2,51 +2,1007 @@
 //
 // This package handles the rasterization of SVG files into PNG format at specific
 // pixel dimensions, serving as the foundation for ICO and ICNS icon generation.
+//
+// Output is deterministic: the encoder writes no tIME or other metadata
+// chunks and always picks the same filters, so rendering the same SVG with the
+// same options yields identical bytes, which reproducible builds and the
+// render cache rely on. Keep it that way when adding encoders or options.
 package png
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
 	"image"
+	"image/color"
+	"image/draw"
 	"image/png"
+	"io"
+	"io/fs"
+	"math"
 	"os"
+	"time"
 
 	"github.com/srwiley/oksvg"
 	"github.com/srwiley/rasterx"
+	xdraw "golang.org/x/image/draw"
 )
 
+// ErrNotSvg is returned when the input doesn't start like an SVG document.
+var ErrNotSvg = errors.New("input is not an SVG file")
+
+// ErrBlankRender is returned when RejectBlank is set and the rendered icon
+// doesn't contain a single visible pixel.
+var ErrBlankRender = errors.New("rendered icon is fully transparent")
+
+// ErrRenderPanic is returned when drawing the SVG panics inside oksvg or
+// rasterx, which happens for some malformed inputs that parse fine. The
+// error wraps it together with the recovered value.
+var ErrRenderPanic = errors.New("rendering the SVG panicked")
+
+// svgSignatures are the prefixes an SVG document may start with once a
+// byte order mark and leading whitespace are skipped.
+var svgSignatures = [][]byte{
+	[]byte("<?xml"),
+	[]byte("<!doctype"),
+	[]byte("<!--"),
+	[]byte("<svg"),
+}
+
+// RenderOptions controls how an SVG is rasterized.
+//
+// Start from DefaultRenderOptions, which renders the SVG antialiased and
+// stretched to fill the whole square canvas.
+type RenderOptions struct {
+	// PreserveAspectRatio keeps the proportions of the SVG's viewBox and centers
+	// the artwork on a transparent square canvas instead of stretching it.
+	PreserveAspectRatio bool
+
+	// Align places the artwork within the square canvas when
+	// PreserveAspectRatio leaves room along one dimension, e.g. AlignTopLeft
+	// to anchor every icon of a set to the same corner of a design grid.
+	// The zero value is AlignCenter.
+	Align Align
+
+	// Background fills the canvas before the SVG is drawn.
+	// A nil Background keeps the canvas fully transparent.
+	Background color.Color
+
+	// Tint replaces every fill and stroke color of the SVG, including
+	// gradients, with a single color, e.g. to derive light and dark theme
+	// variants from one source. Fills and strokes set to none stay unpainted
+	// and opacities are kept. A nil Tint keeps the SVG's colors.
+	Tint color.Color
+
+	// Supersample renders the SVG at Supersample times the requested size and
+	// downscales the result with a Catmull-Rom filter, which gives smoother
+	// edges at small sizes. Values below 2 render directly at the target size.
+	Supersample int
+
+	// GammaCorrect performs the Supersample downscale in linear light instead
+	// of on sRGB values, which keeps antialiased edges and fine detail from
+	// turning darker than they appear at full size. It has no effect without
+	// Supersample. DefaultRenderOptions enables it.
+	GammaCorrect bool
+
+	// RejectBlank makes rendering fail with ErrBlankRender when the result is
+	// fully transparent, which usually means the SVG has no drawable content
+	// or a zero-area viewBox. Leave it unset for intentionally empty icons.
+	RejectBlank bool
+
+	// Antialias smooths the edges of the artwork. Turning it off thresholds
+	// every pixel to fully opaque or fully transparent, which keeps the hard
+	// edges of pixel-art icons at small sizes. Supersample has no effect when
+	// Antialias is off.
+	Antialias bool
+
+	// ViewBox crops the artwork to a rectangle in SVG user units, which is
+	// mapped to the full canvas in place of the SVG's declared viewBox. This
+	// zooms in on the meaningful content of SVGs with generous margins.
+	// A nil ViewBox uses the declared viewBox.
+	ViewBox *ViewBox
+
+	// AutoTrim zooms in on the artwork so its non-transparent pixels fill the
+	// canvas. A first pass at the target size finds their bounding box, which
+	// is expanded to a square and rendered again as the ViewBox. A fully
+	// transparent render is left unchanged; set RejectBlank to have it
+	// reported as ErrBlankRender.
+	AutoTrim bool
+
+	// Logger receives a message when a render starts and when it finishes,
+	// including how long it took and how much canvas memory it allocated.
+	// A nil Logger disables logging.
+	Logger Logger
+
+	// Stats collects the duration and canvas memory of every render.
+	// A nil Stats disables collection.
+	Stats *Stats
+
+	// CompressionLevel sets how hard the PNG encoder compresses the image.
+	// BestCompression noticeably shrinks large renders at the cost of
+	// encoding time. The zero value is DefaultCompression.
+	CompressionLevel CompressionLevel
+
+	// PremultipliedAlpha writes the color samples of encoded PNGs multiplied
+	// by their alpha, for consumers that treat PNG data as premultiplied and
+	// otherwise show dark or light halos around semi-transparent edges.
+	// The PNG specification defines straight alpha, which is written by
+	// default and expected by browsers, image editors and the OS icon loaders.
+	// Fully opaque pixels are identical either way.
+	PremultipliedAlpha bool
+
+	// ColorProfile selects the color-space chunks embedded in encoded PNGs
+	// so color-managed viewers interpret the colors correctly. The zero
+	// value is ColorProfileSRGB.
+	ColorProfile ColorProfile
+
+	// NormalizeStrokes scales stroke widths and dash patterns along with the
+	// artwork. Without it strokes are drawn with their width in SVG user
+	// units as pixels at every size, which makes them proportionally thicker
+	// in small renders and thinner in large ones.
+	NormalizeStrokes bool
+
+	// Padding insets the artwork on every side by this fraction of the size,
+	// leaving transparent margins as many platform icon guidelines require,
+	// e.g. 0.1 for a 10% margin. Values range from 0 up to, but excluding, 0.5.
+	Padding float64
+
+	// RoundedCorners clips the rendered canvas to a rounded square whose
+	// corner radius is this fraction of the size, e.g. about 0.2 for macOS
+	// style app icons. Values range from 0 (no clipping) to 0.5 (a circle).
+	RoundedCorners float64
+
+	// ClipToViewBox restricts drawing to the area the viewBox is mapped to.
+	// By default artwork extending past the viewBox (overflow="visible")
+	// still shows wherever it falls on the canvas, e.g. in the margins left
+	// by Padding or PreserveAspectRatio, and is only cut off at the canvas
+	// edges.
+	ClipToViewBox bool
+
+	// LayerID renders only the top-level <g> group with this id, see
+	// SelectLayer. Layers are selected while parsing, so LayerID is honoured by
+	// functions that parse the SVG themselves such as SvgToPngOpts. Icons that
+	// were parsed up front must be parsed with ParseSvgLayer instead, and
+	// rendering them with LayerID set fails.
+	LayerID string
+
+	// BitDepth sets the bits per channel of encoded PNGs, 8 or 16. A 16-bit
+	// PNG keeps the full precision of the Supersample downscale, which
+	// avoids visible banding in smooth gradients of large renders such as
+	// the 512px and 1024px ICNS entries. Without Supersample the 8-bit render
+	// is stored as is. PostProcess can't be used with 16 bits. The zero value
+	// means 8.
+	BitDepth int
+
+	// PostProcess is called with every rasterized canvas before it is
+	// encoded and may modify its pixels in place, e.g. to add a badge or a
+	// watermark. An error aborts the render. Renders with a PostProcess are
+	// never cached, since the function can't be part of the cache key.
+	// A nil PostProcess encodes the canvas unchanged.
+	PostProcess func(size int, img *image.RGBA) error
+
+	// PerSize overrides these options for specific pixel sizes, e.g. to
+	// supersample only the small sizes of an icon set and render the large
+	// ones directly. An entry replaces the options of its size entirely, so
+	// start it from DefaultRenderOptions; only a nil Logger, Stats or
+	// PostProcess is taken over from these options. Sizes without an entry
+	// are rendered with these options.
+	PerSize map[int]RenderOptions
+
+	allocated *int // Canvas bytes of the current render, tracked only with a Logger or Stats
+}
+
+// forSize returns the effective options for a render at pxSize, see PerSize.
+func (o RenderOptions) forSize(pxSize int) RenderOptions {
+	override, ok := o.PerSize[pxSize]
+	if !ok {
+		return o
+	}
+	if override.Logger == nil {
+		override.Logger = o.Logger
+	}
+	if override.Stats == nil {
+		override.Stats = o.Stats
+	}
+	if override.PostProcess == nil {
+		override.PostProcess = o.PostProcess
+	}
+	override.PerSize = nil
+	return override
+}
+
+// Align selects where artwork with a preserved aspect ratio is placed on the
+// square canvas.
+type Align int
+
+// The placements accepted by RenderOptions.Align.
+const (
+	AlignCenter Align = iota
+	AlignTopLeft
+	AlignTop
+	AlignTopRight
+	AlignLeft
+	AlignRight
+	AlignBottomLeft
+	AlignBottom
+	AlignBottomRight
+)
+
+// offsets returns the fraction of the free horizontal and vertical space
+// placed before the artwork, 0 for the start, 0.5 for the center and 1 for
+// the end.
+func (a Align) offsets() (float64, float64) {
+	switch a {
+	case AlignTopLeft:
+		return 0, 0
+	case AlignTop:
+		return 0.5, 0
+	case AlignTopRight:
+		return 1, 0
+	case AlignLeft:
+		return 0, 0.5
+	case AlignRight:
+		return 1, 0.5
+	case AlignBottomLeft:
+		return 0, 1
+	case AlignBottom:
+		return 0.5, 1
+	case AlignBottomRight:
+		return 1, 1
+	default:
+		return 0.5, 0.5
+	}
+}
+
+// CompressionLevel is the compression level of encoded PNGs.
+type CompressionLevel = png.CompressionLevel
+
+// The compression levels accepted by RenderOptions.
+const (
+	DefaultCompression = png.DefaultCompression
+	NoCompression      = png.NoCompression
+	BestSpeed          = png.BestSpeed
+	BestCompression    = png.BestCompression
+)
+
+// Logger receives progress messages while icons are rendered.
+type Logger interface {
+	Logf(format string, args ...any)
+}
+
+// LoggerFunc adapts an ordinary printf-style function to a Logger.
+type LoggerFunc func(format string, args ...any)
+
+// Logf calls f(format, args...).
+func (f LoggerFunc) Logf(format string, args ...any) {
+	f(format, args...)
+}
+
+// ViewBox is a rectangle in SVG user units.
+type ViewBox struct {
+	MinX, MinY, W, H float64
+}
+
+// DefaultRenderOptions returns the options used by RenderIcon.
+func DefaultRenderOptions() RenderOptions {
+	return RenderOptions{Antialias: true, GammaCorrect: true}
+}
+
 // SvgToPng converts an SVG file to PNG format at the specified pixel size.
 //
 // The function rasterizes the SVG using vector graphics processing to produce
 // high-quality PNG output suitable for icon generation. The SVG is scaled to
 // fit exactly within the specified square dimensions.
 //
+// The file is streamed into SvgToPngReader, so both return the same bytes for
+// the same markup. Callers producing several sizes should use ParseSvg and
+// RenderIcon instead of parsing the file for every size.
+//
 // Parameters:
 //   - svgPath: Path to the source SVG file
 //   - pxSize: Output dimensions in pixels (width and height)
 //
 // Returns the PNG-encoded image data as bytes, or an error if conversion fails.
 func SvgToPng(svgPath string, pxSize int) ([]byte, error) {
-	svgFile, err := os.Open(svgPath)
+	svgFile, err := openSvg(svgPath)
 	if err != nil {
 		return nil, err
 	}
 	defer svgFile.Close()
 
-	icon, err := oksvg.ReadIconStream(svgFile)
+	data, err := SvgToPngReader(svgFile, pxSize)
+	if err != nil {
+		return nil, withPath(svgPath, err)
+	}
+	return data, nil
+}
+
+// SvgToPngWH converts an SVG file to PNG format at the specified width and
+// height, for non-square outputs such as wide tiles.
+//
+// The SVG's viewBox is stretched to the full width x height rectangle, so
+// artwork whose viewBox has the same aspect ratio as the rectangle keeps
+// its proportions.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - w: Output width in pixels
+//   - h: Output height in pixels
+//
+// Returns the PNG-encoded image data as bytes, or an error if conversion fails.
+func SvgToPngWH(svgPath string, w, h int) ([]byte, error) {
+	icon, err := ParseSvg(svgPath)
 	if err != nil {
 		return nil, err
 	}
+	return RenderIconRect(icon, w, h, DefaultRenderOptions())
+}
 
-	canvas := image.NewRGBA(image.Rect(0, 0, pxSize, pxSize))
-	icon.SetTarget(0, 0, float64(pxSize), float64(pxSize))
+// SvgToImage rasterizes an SVG file like SvgToPng but returns the image
+// without encoding it.
+//
+// Callers that composite, analyze or re-encode the icon get the pixels
+// directly instead of decoding the PNG again.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - pxSize: Output dimensions in pixels (width and height)
+//
+// Returns the rasterized image, always an *image.RGBA, or an error if
+// conversion fails.
+func SvgToImage(svgPath string, pxSize int) (image.Image, error) {
+	icon, err := ParseSvg(svgPath)
+	if err != nil {
+		return nil, err
+	}
+	canvas, err := RasterizeIcon(icon, pxSize, DefaultRenderOptions())
+	if err != nil {
+		return nil, err
+	}
+	return canvas, nil
+}
 
-	scanner := rasterx.NewScannerGV(pxSize, pxSize, canvas, canvas.Bounds())
-	raster := rasterx.NewDasher(pxSize, pxSize, scanner)
-	icon.Draw(raster, 1.0)
+// SvgToPngReader converts SVG markup read from r to PNG format at the specified pixel size.
+//
+// It behaves exactly like SvgToPng but accepts any reader as the source, which
+// allows converting SVG content that only exists in memory.
+//
+// Parameters:
+//   - r: Reader providing the SVG markup
+//   - pxSize: Output dimensions in pixels (width and height)
+//
+// Returns the PNG-encoded image data as bytes, or an error if conversion fails.
+func SvgToPngReader(r io.Reader, pxSize int) ([]byte, error) {
+	icon, err := ParseSvgReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return RenderIcon(icon, pxSize)
+}
+
+// SvgToPngOpts converts an SVG file to PNG format at the specified pixel size
+// using the given render options.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - pxSize: Output dimensions in pixels (width and height)
+//   - opts: Options controlling the rasterization
+//
+// Returns the PNG-encoded image data as bytes, or an error if conversion fails.
+func SvgToPngOpts(svgPath string, pxSize int, opts RenderOptions) ([]byte, error) {
+	var icon *oksvg.SvgIcon
+	var err error
+	if opts.LayerID != "" {
+		icon, err = ParseSvgLayer(svgPath, opts.LayerID)
+	} else {
+		icon, err = ParseSvg(svgPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The layer has been selected while parsing
+	opts.LayerID = ""
+	return RenderIconOpts(icon, pxSize, opts)
+}
+
+// ParseSvg reads and parses an SVG file into an icon that can be rendered
+// repeatedly with RenderIcon.
+//
+// Parsing is the most expensive part of the conversion for complex SVGs, so
+// callers producing several sizes should parse once and render many times.
+//
+// Returns the parsed icon, or an error if the file can't be opened or parsed.
+func ParseSvg(svgPath string) (*oksvg.SvgIcon, error) {
+	svgFile, err := openSvg(svgPath)
+	if err != nil {
+		return nil, err
+	}
+	defer svgFile.Close()
+
+	icon, err := ParseSvgReader(svgFile)
+	if err != nil {
+		return nil, withPath(svgPath, err)
+	}
+	return icon, nil
+}
+
+// ParseSvgReader parses SVG markup read from r into an icon that can be
+// rendered repeatedly with RenderIcon.
+//
+// The markup must start like an SVG document, otherwise ErrNotSvg is returned
+// without attempting to parse it. Markup containing elements that can't be
+// rendered, such as <image>, is rejected with ErrUnsupportedElement instead
+// of producing an incomplete icon.
+//
+// Returns the parsed icon, or an error if the markup can't be read or parsed.
+func ParseSvgReader(r io.Reader) (*oksvg.SvgIcon, error) {
+	buffered := bufio.NewReader(r)
+	if err := checkSignature(buffered); err != nil {
+		return nil, err
+	}
+	data, err := readChecked(buffered)
+	if err != nil {
+		return nil, err
+	}
+	return oksvg.ReadIconStream(bytes.NewReader(data))
+}
+
+// checkSignature peeks at the start of the stream and returns ErrNotSvg unless
+// it begins with an XML declaration, a DOCTYPE, a comment or an <svg> element.
+// Leading whitespace and a UTF-8 byte order mark are ignored.
+func checkSignature(r *bufio.Reader) error {
+	head, err := r.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return err
+	}
+
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	head = bytes.ToLower(bytes.TrimLeft(head, " \t\r\n"))
+	for _, signature := range svgSignatures {
+		if bytes.HasPrefix(head, signature) {
+			return nil
+		}
+	}
+	return ErrNotSvg
+}
+
+// withPath adds the file path to ErrNotSvg, ErrUnsupportedElement and
+// ErrUnsupportedFeature so the user can tell which input was rejected. Other
+// errors already name the file or are returned unchanged.
+func withPath(svgPath string, err error) error {
+	if errors.Is(err, ErrNotSvg) || errors.Is(err, ErrUnsupportedElement) || errors.Is(err, ErrUnsupportedFeature) {
+		return fmt.Errorf("%s: %w", svgPath, err)
+	}
+	return err
+}
+
+// openSvg opens the SVG file at svgPath. Errors name svgPath as given by the
+// caller, and a symbolic link whose target is missing is reported as a
+// broken link rather than as a missing file.
+func openSvg(svgPath string) (*os.File, error) {
+	file, err := os.Open(svgPath)
+	if err != nil {
+		return nil, openError(svgPath, err)
+	}
+	return file, nil
+}
+
+// ReadSvg reads the markup of the SVG file at svgPath without parsing it.
+//
+// Errors name svgPath as given by the caller, and a symbolic link whose
+// target is missing is reported as a broken link, like the errors of
+// ParseSvg.
+//
+// Parameters:
+//   - svgPath: Path to the SVG file
+//
+// Returns the file contents, or an error if the file can't be read.
+func ReadSvg(svgPath string) ([]byte, error) {
+	data, err := os.ReadFile(svgPath)
+	if err != nil {
+		return nil, openError(svgPath, err)
+	}
+	return data, nil
+}
+
+// openError replaces the "no such file" error of a dangling symbolic link
+// with one naming the missing link target. Other errors are returned
+// unchanged, they already name svgPath.
+func openError(svgPath string, err error) error {
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	target, linkErr := os.Readlink(svgPath)
+	if linkErr != nil {
+		return err
+	}
+	return &fs.PathError{Op: "open", Path: svgPath, Err: fmt.Errorf("broken symbolic link to %s: %w", target, fs.ErrNotExist)}
+}
+
+// RenderIcon rasterizes a parsed SVG icon to PNG format at the specified pixel size.
+//
+// The icon is scaled to fit exactly within the specified square dimensions.
+// The parsed icon itself is not modified, so it is safe to render the same
+// icon from multiple goroutines.
+//
+// Parameters:
+//   - icon: Parsed SVG icon as returned by ParseSvg
+//   - pxSize: Output dimensions in pixels (width and height)
+//
+// Returns the PNG-encoded image data as bytes, or an error if encoding fails.
+func RenderIcon(icon *oksvg.SvgIcon, pxSize int) ([]byte, error) {
+	return RenderIconOpts(icon, pxSize, DefaultRenderOptions())
+}
+
+// RenderIconOpts rasterizes a parsed SVG icon to PNG format at the specified
+// pixel size using the given render options.
+//
+// Parameters:
+//   - icon: Parsed SVG icon as returned by ParseSvg
+//   - pxSize: Output dimensions in pixels (width and height)
+//   - opts: Options controlling the rasterization
+//
+// Returns the PNG-encoded image data as bytes, or an error if encoding fails.
+func RenderIconOpts(icon *oksvg.SvgIcon, pxSize int, opts RenderOptions) ([]byte, error) {
+	opts = opts.forSize(pxSize)
+	switch opts.BitDepth {
+	case 0, 8:
+	case 16:
+		if opts.PostProcess != nil {
+			return nil, errors.New("PostProcess needs an 8-bit canvas and can't be combined with a BitDepth of 16")
+		}
+		canvas, err := rasterizeIcon(icon, pxSize, opts, true)
+		if err != nil {
+			return nil, err
+		}
+		return encodePng(canvas, opts)
+	default:
+		return nil, fmt.Errorf("invalid bit depth %d: must be 8 or 16", opts.BitDepth)
+	}
+
+	canvas, err := RasterizeIcon(icon, pxSize, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PostProcess != nil {
+		if err := opts.PostProcess(pxSize, canvas); err != nil {
+			return nil, fmt.Errorf("post-processing %dx%d: %w", pxSize, pxSize, err)
+		}
+	}
+	return encodePng(canvas, opts)
+}
+
+// RenderIconRect rasterizes a parsed SVG icon to PNG format at width x height
+// pixels using the given render options, for non-square outputs such as wide
+// tiles.
+//
+// The viewBox is stretched to the full rectangle unless
+// opts.PreserveAspectRatio is set, which fits it inside the rectangle and
+// places it according to opts.Align. Supersample, AutoTrim, RoundedCorners,
+// RejectBlank, PostProcess and PerSize only apply to square renders and are
+// ignored; the encoding options such as CompressionLevel, ColorProfile and
+// BitDepth apply as usual.
+//
+// Parameters:
+//   - icon: Parsed SVG icon as returned by ParseSvg
+//   - width: Output width in pixels
+//   - height: Output height in pixels
+//   - opts: Options controlling the rasterization
+//
+// Returns the PNG-encoded image data as bytes, or an error if rendering or
+// encoding fails.
+func RenderIconRect(icon *oksvg.SvgIcon, width int, height int, opts RenderOptions) ([]byte, error) {
+	if width < 1 || height < 1 {
+		return nil, fmt.Errorf("invalid size %dx%d: width and height must be positive", width, height)
+	}
+	if opts.Padding < 0 || opts.Padding >= 0.5 {
+		return nil, fmt.Errorf("invalid padding %g: must be at least 0 and below 0.5", opts.Padding)
+	}
+	canvas, err := rasterizeRect(icon, width, height, opts)
+	if err != nil {
+		return nil, err
+	}
+	switch opts.BitDepth {
+	case 0, 8:
+		return encodePng(canvas, opts)
+	case 16:
+		return encodePng(widen(canvas), opts)
+	}
+	return nil, fmt.Errorf("invalid bit depth %d: must be 8 or 16", opts.BitDepth)
+}
+
+// encodePng encodes the canvas as PNG with the compression level, alpha
+// representation and color profile selected by opts. A canvas with 16 bits
+// per channel is encoded as a 16-bit PNG.
+func encodePng(canvas draw.Image, opts RenderOptions) ([]byte, error) {
+	// The canvas stores premultiplied samples, which the encoder converts to
+	// straight alpha unless they are passed through unchanged as NRGBA
+	var img image.Image = canvas
+	if opts.PremultipliedAlpha {
+		switch canvas := canvas.(type) {
+		case *image.RGBA:
+			img = &image.NRGBA{Pix: canvas.Pix, Stride: canvas.Stride, Rect: canvas.Rect}
+		case *image.RGBA64:
+			img = &image.NRGBA64{Pix: canvas.Pix, Stride: canvas.Stride, Rect: canvas.Rect}
+		}
+	}
 
 	var buffer bytes.Buffer
-	if err := png.Encode(&buffer, canvas); err != nil {
+	encoder := png.Encoder{CompressionLevel: opts.CompressionLevel}
+	if err := encoder.Encode(&buffer, img); err != nil {
+		return nil, err
+	}
+	return injectColorProfile(buffer.Bytes(), opts.ColorProfile)
+}
+
+// RasterizeIcon draws a parsed SVG icon onto a new RGBA canvas of the specified
+// pixel size without encoding it.
+//
+// This is the rasterization step shared by all encoders, for callers that
+// need the raw pixels (e.g. to store them in a different image format).
+//
+// Parameters:
+//   - icon: Parsed SVG icon as returned by ParseSvg
+//   - pxSize: Output dimensions in pixels (width and height)
+//   - opts: Options controlling the rasterization
+//
+// The canvas always has 8 bits per channel, BitDepth only applies to
+// encoded PNGs.
+//
+// Returns the rendered canvas, or an error if rasterization fails.
+func RasterizeIcon(icon *oksvg.SvgIcon, pxSize int, opts RenderOptions) (*image.RGBA, error) {
+	canvas, err := rasterizeIcon(icon, pxSize, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	return canvas.(*image.RGBA), nil
+}
+
+// rasterizeIcon draws the icon like RasterizeIcon, onto a canvas with 16 bits
+// per channel (*image.RGBA64) if deep is set, or 8 bits (*image.RGBA)
+// otherwise.
+func rasterizeIcon(icon *oksvg.SvgIcon, pxSize int, opts RenderOptions, deep bool) (draw.Image, error) {
+	opts = opts.forSize(pxSize)
+	if opts.ViewBox != nil && (opts.ViewBox.W <= 0 || opts.ViewBox.H <= 0) {
+		return nil, fmt.Errorf("invalid viewBox %gx%g: width and height must be positive", opts.ViewBox.W, opts.ViewBox.H)
+	}
+	if opts.LayerID != "" {
+		return nil, errors.New("LayerID can't be applied to a parsed icon, parse it with ParseSvgLayer")
+	}
+	if opts.Padding < 0 || opts.Padding >= 0.5 {
+		return nil, fmt.Errorf("invalid padding %g: must be at least 0 and below 0.5", opts.Padding)
+	}
+	if opts.RoundedCorners < 0 || opts.RoundedCorners > 0.5 {
+		return nil, fmt.Errorf("invalid corner radius %g: must be between 0 and 0.5", opts.RoundedCorners)
+	}
+	if opts.Logger != nil || opts.Stats != nil {
+		start := time.Now()
+		logger, stats := opts.Logger, opts.Stats
+		allocated := 0
+		opts.allocated = &allocated
+		if logger != nil {
+			logger.Logf("rendering %dx%d", pxSize, pxSize)
+		}
+		defer func() {
+			duration := time.Since(start)
+			if logger != nil {
+				logger.Logf("rendered %dx%d in %s, %s of canvases", pxSize, pxSize, duration.Round(time.Microsecond), formatBytes(allocated))
+			}
+			if stats != nil {
+				stats.add(RenderStat{Size: pxSize, Duration: duration, CanvasBytes: allocated})
+			}
+		}()
+	}
+	if opts.AutoTrim {
+		var err error
+		if opts, err = trimOptions(icon, pxSize, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	var canvas draw.Image
+	if opts.Supersample < 2 || !opts.Antialias {
+		small, err := rasterize(icon, pxSize, opts)
+		if err != nil {
+			return nil, err
+		}
+		canvas = small
+		if deep {
+			canvas = widen(small)
+			opts.trackCanvas(2 * len(small.Pix))
+		}
+	} else {
+		// Render at the higher resolution and filter down to the target size
+		large, err := rasterize(icon, pxSize*opts.Supersample, opts)
+		if err != nil {
+			return nil, err
+		}
+		canvas = newCanvas(pxSize, deep)
+		opts.trackCanvas(canvasBytes(canvas))
+		if opts.GammaCorrect {
+			// The linear copies hold 16 bits per channel
+			opts.trackCanvas(2*len(large.Pix) + 8*pxSize*pxSize)
+			scaleLinear(canvas, large)
+		} else {
+			xdraw.CatmullRom.Scale(canvas, canvas.Bounds(), large, large.Bounds(), draw.Src, nil)
+		}
+	}
+
+	if opts.RoundedCorners > 0 {
+		roundCorners(canvas, opts.RoundedCorners*float64(pxSize), opts.Antialias)
+	}
+
+	if opts.RejectBlank && blank(canvas) {
+		return nil, ErrBlankRender
+	}
+
+	return canvas, nil
+}
+
+// newCanvas returns a transparent square canvas of pxSize pixels with 16
+// bits per channel if deep is set, or 8 bits otherwise.
+func newCanvas(pxSize int, deep bool) draw.Image {
+	if deep {
+		return image.NewRGBA64(image.Rect(0, 0, pxSize, pxSize))
+	}
+	return image.NewRGBA(image.Rect(0, 0, pxSize, pxSize))
+}
+
+// canvasBytes returns the pixel memory of a canvas created by newCanvas.
+func canvasBytes(canvas draw.Image) int {
+	if deep, ok := canvas.(*image.RGBA64); ok {
+		return len(deep.Pix)
+	}
+	return len(canvas.(*image.RGBA).Pix)
+}
+
+// widen copies an 8-bit canvas into a new canvas with 16 bits per channel.
+func widen(img *image.RGBA) *image.RGBA64 {
+	out := image.NewRGBA64(img.Bounds())
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Src)
+	return out
+}
+
+// blank reports whether every pixel of a canvas created by newCanvas is
+// fully transparent.
+func blank(canvas draw.Image) bool {
+	deep, ok := canvas.(*image.RGBA64)
+	if !ok {
+		return VisiblePixels(canvas.(*image.RGBA)) == 0
+	}
+	for i := 6; i < len(deep.Pix); i += 8 {
+		if deep.Pix[i] != 0 || deep.Pix[i+1] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// VisiblePixels counts the pixels of img that aren't fully transparent.
+func VisiblePixels(img *image.RGBA) int {
+	count := 0
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(img.Rect.Min.X, y):img.PixOffset(img.Rect.Max.X, y)]
+		for i := 3; i < len(row); i += 4 {
+			if row[i] != 0 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// trimOptions returns opts with the ViewBox set to the square around the
+// visible artwork, found by a first render at pxSize. The options are returned
+// without AutoTrim if the render is fully transparent, or with an error if the
+// first render fails.
+func trimOptions(icon *oksvg.SvgIcon, pxSize int, opts RenderOptions) (RenderOptions, error) {
+	opts.AutoTrim = false
+
+	// Detect the artwork without a background, which would cover everything
+	detectOpts := opts
+	detectOpts.Background = nil
+	detected, err := rasterize(icon, pxSize, detectOpts)
+	if err != nil {
+		return opts, err
+	}
+	bounds := opaqueBounds(detected)
+	if bounds.Empty() {
+		return opts, nil
+	}
+
+	// Expand the bounds to a square around their center
+	side := float64(max(bounds.Dx(), bounds.Dy()))
+	centerX := float64(bounds.Min.X+bounds.Max.X) / 2
+	centerY := float64(bounds.Min.Y+bounds.Max.Y) / 2
+
+	// Map the square back to user units through the first render's transform
+	target := *icon
+	setTarget(&target, pxSize, opts)
+	inverse := target.Transform.Invert()
+	minX, minY := inverse.Transform(centerX-side/2, centerY-side/2)
+	maxX, maxY := inverse.Transform(centerX+side/2, centerY+side/2)
+
+	opts.ViewBox = &ViewBox{MinX: minX, MinY: minY, W: maxX - minX, H: maxY - minY}
+	// Keep the scale ratio of the first render, the square already accounts for it
+	opts.PreserveAspectRatio = false
+	return opts, nil
+}
+
+// opaqueBounds returns the bounding box of the pixels of img that aren't
+// fully transparent, or an empty rectangle if there are none.
+func opaqueBounds(img *image.RGBA) image.Rectangle {
+	bounds := image.Rectangle{}
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if img.Pix[img.PixOffset(x, y)+3] != 0 {
+				bounds = bounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return bounds
+}
+
+// rasterize draws the icon onto a new square canvas of pxSize pixels.
+func rasterize(icon *oksvg.SvgIcon, pxSize int, opts RenderOptions) (*image.RGBA, error) {
+	return rasterizeRect(icon, pxSize, pxSize, opts)
+}
+
+// rasterizeRect draws the icon onto a new canvas of width x height pixels.
+// Returns an error wrapping ErrRenderPanic if drawing panics.
+func rasterizeRect(icon *oksvg.SvgIcon, width int, height int, opts RenderOptions) (*image.RGBA, error) {
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	opts.trackCanvas(len(canvas.Pix))
+	if opts.Background != nil {
+		draw.Draw(canvas, canvas.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	}
+	// Position a copy so concurrent renders don't share the transform
+	target := *icon
+	setTargetRect(&target, width, height, opts)
+	if opts.NormalizeStrokes {
+		normalizeStrokes(&target)
+	}
+	if opts.Tint != nil {
+		tint(&target, opts.Tint)
+	}
+	clip := image.Rectangle{}
+	if opts.ClipToViewBox {
+		clip = viewBoxBounds(&target, opts)
+	}
+
+	if !opts.Antialias {
+		// Threshold the artwork on its own layer so the background isn't affected
+		layer := image.NewRGBA(canvas.Bounds())
+		opts.trackCanvas(len(layer.Pix))
+		if err := drawIcon(&target, layer, clip); err != nil {
+			return nil, err
+		}
+		threshold(layer)
+		draw.Draw(canvas, canvas.Bounds(), layer, image.Point{}, draw.Over)
+		return canvas, nil
+	}
+
+	if err := drawIcon(&target, canvas, clip); err != nil {
 		return nil, err
 	}
-	return buffer.Bytes(), nil
+	return canvas, nil
+}
+
+// viewBoxBounds returns the pixel rectangle the viewBox of the positioned
+// icon is mapped to, rounded to whole pixels.
+func viewBoxBounds(icon *oksvg.SvgIcon, opts RenderOptions) image.Rectangle {
+	viewBox := ViewBox{icon.ViewBox.X, icon.ViewBox.Y, icon.ViewBox.W, icon.ViewBox.H}
+	if opts.ViewBox != nil {
+		viewBox = *opts.ViewBox
+	}
+	x0, y0 := icon.Transform.Transform(viewBox.MinX, viewBox.MinY)
+	x1, y1 := icon.Transform.Transform(viewBox.MinX+viewBox.W, viewBox.MinY+viewBox.H)
+	return image.Rect(int(math.Round(x0)), int(math.Round(y0)), int(math.Round(x1)), int(math.Round(y1)))
+}
+
+// drawIcon draws a positioned icon onto canvas, leaving the pixels outside
+// clip untouched. An empty clip draws onto the whole canvas.
+//
+// oksvg and rasterx panic on some malformed inputs instead of returning an
+// error, so a panic while drawing is recovered and returned as an error
+// wrapping ErrRenderPanic, keeping programs embedding the library alive.
+func drawIcon(icon *oksvg.SvgIcon, canvas *image.RGBA, clip image.Rectangle) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%w: %v", ErrRenderPanic, recovered)
+		}
+	}()
+
+	width, height := canvas.Rect.Dx(), canvas.Rect.Dy()
+	scanner := rasterx.NewScannerGV(width, height, canvas, canvas.Bounds())
+	if !clip.Empty() {
+		scanner.SetClip(clip)
+	}
+	raster := rasterx.NewDasher(width, height, scanner)
+	icon.Draw(raster, 1.0)
+	return nil
+}
+
+// threshold makes every pixel of img either fully opaque or fully
+// transparent, removing the partially covered pixels along the edges.
+func threshold(img *image.RGBA) {
+	for i := 0; i < len(img.Pix); i += 4 {
+		alpha := uint32(img.Pix[i+3])
+		if alpha < 128 {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 0, 0, 0, 0
+			continue
+		}
+		// Un-premultiply the color at full opacity
+		for c := 0; c < 3; c++ {
+			img.Pix[i+c] = uint8(min(uint32(img.Pix[i+c])*255/alpha, 255))
+		}
+		img.Pix[i+3] = 255
+	}
+}
+
+// normalizeStrokes scales the stroke widths and dash patterns of a positioned
+// icon by the scale of its transform. The paths are copied, so the parsed
+// icon shared between renders isn't modified.
+func normalizeStrokes(icon *oksvg.SvgIcon) {
+	t := icon.Transform
+	scale := math.Sqrt(math.Abs(t.A*t.D - t.B*t.C))
+
+	paths := make([]oksvg.SvgPath, len(icon.SVGPaths))
+	for i, path := range icon.SVGPaths {
+		path.LineWidth *= scale
+		path.DashOffset *= scale
+		if path.Dash != nil {
+			dash := make([]float64, len(path.Dash))
+			for j, length := range path.Dash {
+				dash[j] = length * scale
+			}
+			path.Dash = dash
+		}
+		paths[i] = path
+	}
+	icon.SVGPaths = paths
+}
+
+// setTarget positions the icon on a square canvas of pxSize pixels.
+// Without PreserveAspectRatio the viewBox is stretched to the full canvas,
+// otherwise it is scaled uniformly and placed according to opts.Align.
+// Padding shrinks the area the viewBox is mapped to by the same margin on
+// every side.
+func setTarget(icon *oksvg.SvgIcon, pxSize int, opts RenderOptions) {
+	setTargetRect(icon, pxSize, pxSize, opts)
+}
+
+// setTargetRect positions the icon like setTarget on a canvas of
+// canvasWidth x canvasHeight pixels. The Padding margin is a fraction of the
+// shorter side.
+func setTargetRect(icon *oksvg.SvgIcon, canvasWidth int, canvasHeight int, opts RenderOptions) {
+	viewBox := ViewBox{icon.ViewBox.X, icon.ViewBox.Y, icon.ViewBox.W, icon.ViewBox.H}
+	if opts.ViewBox != nil {
+		viewBox = *opts.ViewBox
+	}
+
+	margin := float64(min(canvasWidth, canvasHeight)) * opts.Padding
+	innerWidth := float64(canvasWidth) - 2*margin
+	innerHeight := float64(canvasHeight) - 2*margin
+	x, y, width, height := margin, margin, innerWidth, innerHeight
+	if opts.PreserveAspectRatio && viewBox.W > 0 && viewBox.H > 0 {
+		scale := math.Min(innerWidth/viewBox.W, innerHeight/viewBox.H)
+		width = viewBox.W * scale
+		height = viewBox.H * scale
+		alignX, alignY := opts.Align.offsets()
+		x, y = margin+(innerWidth-width)*alignX, margin+(innerHeight-height)*alignY
+	}
+
+	if opts.ViewBox == nil {
+		icon.SetTarget(x, y, width, height)
+		return
+	}
+	// SetTarget only works with the declared viewBox, so map the crop
+	// rectangle onto the target area directly
+	icon.Transform = rasterx.Identity.
+		Translate(x, y).
+		Scale(width/viewBox.W, height/viewBox.H).
+		Translate(-viewBox.MinX, -viewBox.MinY)
 }
@@ -0,0 +1,409 @@
+package png
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/srwiley/oksvg"
+)
+
+// triangleSvg has diagonal edges and a curve, which show antialiasing at
+// every size.
+const triangleSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">
+<path d="M8 56 L32 6 L56 56 Z" fill="#000"/>
+<circle cx="32" cy="40" r="9" fill="#fff"/>
+</svg>`
+
+// parseTest parses the SVG markup svg.
+func parseTest(t *testing.T, svg string) *oksvg.SvgIcon {
+	t.Helper()
+	icon, err := ParseSvgReader(bytes.NewReader([]byte(svg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return icon
+}
+
+// rasterizeTest parses svg and rasterizes it at pxSize with opts.
+func rasterizeTest(t *testing.T, svg string, pxSize int, opts RenderOptions) *image.RGBA {
+	t.Helper()
+	img, err := RasterizeIcon(parseTest(t, svg), pxSize, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// edgePixels returns the number of partially transparent pixels of img.
+func edgePixels(img *image.RGBA) int {
+	count := 0
+	for i := 3; i < len(img.Pix); i += 4 {
+		if alpha := img.Pix[i]; alpha > 0 && alpha < 255 {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSupersample(t *testing.T) {
+	direct := rasterizeTest(t, triangleSvg, 16, DefaultRenderOptions())
+
+	tests := []struct {
+		factor   int
+		smoother bool // More edge pixels than the direct render
+	}{
+		{factor: 0},
+		{factor: 1},
+		{factor: 2, smoother: true},
+		{factor: 4, smoother: true},
+	}
+	for _, test := range tests {
+		opts := DefaultRenderOptions()
+		opts.Supersample = test.factor
+		img := rasterizeTest(t, triangleSvg, 16, opts)
+		if bounds := img.Bounds(); bounds.Dx() != 16 || bounds.Dy() != 16 {
+			t.Fatalf("factor %d: got %dx%d, want 16x16", test.factor, bounds.Dx(), bounds.Dy())
+		}
+
+		edges, directEdges := edgePixels(img), edgePixels(direct)
+		switch {
+		case test.smoother && edges <= directEdges:
+			t.Errorf("factor %d: %d edge pixels, direct render has %d", test.factor, edges, directEdges)
+		case !test.smoother && !bytes.Equal(img.Pix, direct.Pix):
+			t.Errorf("factor %d: differs from the direct render", test.factor)
+		}
+	}
+}
+
+func TestAntialias(t *testing.T) {
+	tests := []struct {
+		antialias   bool
+		supersample int
+		hardEdges   bool // Every pixel fully opaque or fully transparent
+	}{
+		{antialias: true, hardEdges: false},
+		{antialias: true, supersample: 4, hardEdges: false},
+		{antialias: false, hardEdges: true},
+		{antialias: false, supersample: 4, hardEdges: true},
+	}
+	for _, test := range tests {
+		opts := DefaultRenderOptions()
+		opts.Antialias = test.antialias
+		opts.Supersample = test.supersample
+		img := rasterizeTest(t, triangleSvg, 32, opts)
+
+		edges := edgePixels(img)
+		if test.hardEdges && edges != 0 {
+			t.Errorf("antialias %v, supersample %d: %d partially transparent pixels", test.antialias, test.supersample, edges)
+		}
+		if !test.hardEdges && edges == 0 {
+			t.Errorf("antialias %v, supersample %d: no partially transparent pixels", test.antialias, test.supersample)
+		}
+		if VisiblePixels(img) == 0 {
+			t.Errorf("antialias %v, supersample %d: render is blank", test.antialias, test.supersample)
+		}
+	}
+}
+
+// strokeSvg draws only strokes, 4 user units wide.
+const strokeSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">
+<path d="M8 8 L56 8 L56 56 L8 56 Z M8 32 L56 32 M32 8 L32 56" fill="none" stroke="#000" stroke-width="4"/>
+</svg>`
+
+// coverage returns the summed alpha of img as a fraction of a fully opaque
+// image of the same size.
+func coverage(img *image.RGBA) float64 {
+	total := 0
+	for i := 3; i < len(img.Pix); i += 4 {
+		total += int(img.Pix[i])
+	}
+	return float64(total) / float64(len(img.Pix)/4*255)
+}
+
+func TestNormalizeStrokes(t *testing.T) {
+	tests := []struct {
+		normalize bool
+		minRatio  float64 // Bounds of the coverage at 16px over the coverage at 256px
+		maxRatio  float64 // 0 for no upper bound
+	}{
+		{normalize: false, minRatio: 3},
+		{normalize: true, minRatio: 0.8, maxRatio: 1.25},
+	}
+	for _, test := range tests {
+		opts := DefaultRenderOptions()
+		opts.NormalizeStrokes = test.normalize
+		small := coverage(rasterizeTest(t, strokeSvg, 16, opts))
+		large := coverage(rasterizeTest(t, strokeSvg, 256, opts))
+
+		ratio := small / large
+		if ratio < test.minRatio || (test.maxRatio > 0 && ratio > test.maxRatio) {
+			t.Errorf("normalize %v: 16px covers %.3f, 256px %.3f, ratio %.2f", test.normalize, small, large, ratio)
+		}
+	}
+}
+
+// edgeSvg has a semi-transparent white half and an opaque circle with
+// antialiased edges.
+const edgeSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 32 32">
+<rect width="16" height="32" fill="#fff" fill-opacity="0.5"/>
+<circle cx="24" cy="16" r="6.3" fill="#f80"/>
+</svg>`
+
+func TestPremultipliedAlpha(t *testing.T) {
+	background := color.RGBA{B: 255, A: 255}
+	canvas := rasterizeTest(t, edgeSvg, 32, DefaultRenderOptions())
+
+	tests := []struct {
+		premultiplied bool
+		half          color.NRGBA // Stored sample of the semi-transparent half
+	}{
+		{premultiplied: false, half: color.NRGBA{255, 255, 255, 127}},
+		{premultiplied: true, half: color.NRGBA{127, 127, 127, 127}},
+	}
+	for _, test := range tests {
+		opts := DefaultRenderOptions()
+		opts.PremultipliedAlpha = test.premultiplied
+		data, err := RenderIconOpts(parseTest(t, edgeSvg), 32, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The decoder returns the stored samples unchanged as straight alpha
+		stored, ok := decoded.(*image.NRGBA)
+		if !ok {
+			t.Fatalf("premultiplied %v: decoded a %T, want *image.NRGBA", test.premultiplied, decoded)
+		}
+
+		if got := stored.NRGBAAt(4, 16); got != test.half {
+			t.Errorf("premultiplied %v: semi-transparent sample %v, want %v", test.premultiplied, got, test.half)
+		}
+
+		// Composited with the formula for its alpha representation, every
+		// pixel matches the canvas drawn over the background
+		edges := 0
+		for y := 0; y < 32; y++ {
+			for x := 0; x < 32; x++ {
+				sample, want := stored.NRGBAAt(x, y), canvas.RGBAAt(x, y)
+				if want.A > 0 && want.A < 255 {
+					edges++
+				}
+				got := over(sample, background, test.premultiplied)
+				want = over(color.NRGBA(want), background, true)
+				if diff(got.R, want.R) > 1 || diff(got.G, want.G) > 1 || diff(got.B, want.B) > 1 {
+					t.Fatalf("premultiplied %v: pixel %d,%d composites to %v, want %v", test.premultiplied, x, y, got, want)
+				}
+			}
+		}
+		if edges == 0 {
+			t.Fatal("the render has no partially transparent pixels")
+		}
+	}
+}
+
+// over composites the sample s over the opaque background bg, treating the
+// color of s as premultiplied or straight alpha.
+func over(s color.NRGBA, bg color.RGBA, premultiplied bool) color.RGBA {
+	channel := func(c, b uint8) uint8 {
+		value := float64(c)
+		if !premultiplied {
+			value = value * float64(s.A) / 255
+		}
+		return uint8(value + float64(b)*(255-float64(s.A))/255 + 0.5)
+	}
+	return color.RGBA{channel(s.R, bg.R), channel(s.G, bg.G), channel(s.B, bg.B), 255}
+}
+
+// diff returns the absolute difference of a and b.
+func diff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// overflowSvg draws a rectangle reaching 5 user units past every side of
+// its viewBox.
+const overflowSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+<rect x="-5" y="-5" width="20" height="20" fill="#000"/>
+</svg>`
+
+func TestClipToViewBox(t *testing.T) {
+	// With a 25% padding the viewBox maps to 16,16-48,48 on the 64px canvas
+	inside := []image.Point{{16, 16}, {32, 32}, {47, 47}}
+	margin := []image.Point{{15, 15}, {8, 32}, {32, 8}, {56, 56}}
+
+	tests := []struct {
+		clip          bool
+		marginVisible bool
+	}{
+		{clip: false, marginVisible: true},
+		{clip: true, marginVisible: false},
+	}
+	for _, test := range tests {
+		opts := DefaultRenderOptions()
+		opts.Padding = 0.25
+		opts.ClipToViewBox = test.clip
+		img := rasterizeTest(t, overflowSvg, 64, opts)
+
+		for _, point := range inside {
+			if alpha := img.RGBAAt(point.X, point.Y).A; alpha != 255 {
+				t.Errorf("clip %v: pixel %v inside the viewBox has alpha %d", test.clip, point, alpha)
+			}
+		}
+		for _, point := range margin {
+			if visible := img.RGBAAt(point.X, point.Y).A != 0; visible != test.marginVisible {
+				t.Errorf("clip %v: pixel %v past the viewBox visible %v, want %v", test.clip, point, visible, test.marginVisible)
+			}
+		}
+		// Artwork is always cut off at the canvas edges
+		if bounds := img.Bounds(); bounds != image.Rect(0, 0, 64, 64) {
+			t.Errorf("clip %v: canvas is %v", test.clip, bounds)
+		}
+	}
+}
+
+// opaqueGradientSvg is a smooth opaque gradient, which shows banding in
+// 8-bit renders.
+const opaqueGradientSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">
+<defs><linearGradient id="g" x2="1"><stop offset="0" stop-color="#102030"/><stop offset="1" stop-color="#203040"/></linearGradient></defs>
+<rect width="64" height="64" fill="url(#g)"/>
+</svg>`
+
+func TestBitDepth(t *testing.T) {
+	tests := []struct {
+		bitDepth    int
+		postProcess bool
+		ihdr        uint8 // Bit depth in the IHDR chunk, 0 if rendering fails
+	}{
+		{bitDepth: 0, ihdr: 8},
+		{bitDepth: 8, ihdr: 8},
+		{bitDepth: 16, ihdr: 16},
+		{bitDepth: 8, postProcess: true, ihdr: 8},
+		{bitDepth: 16, postProcess: true},
+		{bitDepth: 12},
+		{bitDepth: -1},
+	}
+	for _, test := range tests {
+		opts := DefaultRenderOptions()
+		opts.Supersample = 4
+		opts.BitDepth = test.bitDepth
+		if test.postProcess {
+			opts.PostProcess = func(int, *image.RGBA) error { return nil }
+		}
+		data, err := RenderIconOpts(parseTest(t, opaqueGradientSvg), 64, opts)
+		if test.ihdr == 0 {
+			if err == nil {
+				t.Errorf("bit depth %d, post-process %v: rendering succeeded", test.bitDepth, test.postProcess)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("bit depth %d, post-process %v: %v", test.bitDepth, test.postProcess, err)
+		}
+		// Signature (8), chunk length (4), "IHDR" (4), width (4), height (4), bit depth (1)
+		if got := data[24]; got != test.ihdr {
+			t.Errorf("bit depth %d, post-process %v: IHDR bit depth %d, want %d", test.bitDepth, test.postProcess, got, test.ihdr)
+		}
+	}
+}
+
+func TestBitDepth16KeepsSamples(t *testing.T) {
+	opts := DefaultRenderOptions()
+	opts.Supersample = 4
+	opts.BitDepth = 16
+	canvas, err := rasterizeIcon(parseTest(t, opaqueGradientSvg), 64, opts, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deep, ok := canvas.(*image.RGBA64)
+	if !ok {
+		t.Fatalf("rendered a %T, want *image.RGBA64", canvas)
+	}
+
+	data, err := RenderIconOpts(parseTest(t, opaqueGradientSvg), 64, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every sample round-trips unchanged, including the low byte that an
+	// 8-bit render can't represent
+	fine := 0
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			want := deep.RGBA64At(x, y)
+			if got := color.RGBA64Model.Convert(decoded.At(x, y)).(color.RGBA64); got != want {
+				t.Fatalf("pixel %d,%d decodes to %v, want %v", x, y, got, want)
+			}
+			if want.R%0x101 != 0 || want.G%0x101 != 0 || want.B%0x101 != 0 {
+				fine++
+			}
+		}
+	}
+	if fine == 0 {
+		t.Error("no sample uses more than 8 bits of precision")
+	}
+}
+
+// panicSvg makes rasterx divide by zero while drawing: the repeating radial
+// gradient has a zero radius and the stroked path NaN coordinates.
+const panicSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+<defs><radialGradient id="r" fx="20" r="0" spreadMethod="repeat"><stop offset="1"/></radialGradient></defs>
+<path d="T 1e308 1 NaN " stroke="url(#r)" stroke-linecap="round"/>
+</svg>`
+
+func TestRenderPanicIsRecovered(t *testing.T) {
+	tests := []struct {
+		name   string
+		render func() error
+	}{
+		{"drawIcon", func() error {
+			icon, err := ParseSvgReader(bytes.NewReader([]byte(panicSvg)))
+			if err != nil {
+				return err
+			}
+			setTarget(icon, 32, DefaultRenderOptions())
+			return drawIcon(icon, image.NewRGBA(image.Rect(0, 0, 32, 32)), image.Rectangle{})
+		}},
+		{"RenderIconOpts", func() error {
+			icon, err := ParseSvgReader(bytes.NewReader([]byte(panicSvg)))
+			if err != nil {
+				return err
+			}
+			_, err = RenderIconOpts(icon, 32, DefaultRenderOptions())
+			return err
+		}},
+		{"Supersample", func() error {
+			icon, err := ParseSvgReader(bytes.NewReader([]byte(panicSvg)))
+			if err != nil {
+				return err
+			}
+			opts := DefaultRenderOptions()
+			opts.Supersample = 4
+			_, err = RasterizeIcon(icon, 16, opts)
+			return err
+		}},
+		{"Validate", func() error {
+			return Validate([]byte(panicSvg))
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// A panic escaping the render fails the test instead of the assertion
+			err := test.render()
+			if !errors.Is(err, ErrRenderPanic) {
+				t.Fatalf("got %v, want an error wrapping ErrRenderPanic", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package png
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RenderStat describes the cost of a single render.
+type RenderStat struct {
+	Size        int           // Pixel size of the render
+	Duration    time.Duration // Time spent rasterizing, excluding PNG encoding
+	CanvasBytes int           // Bytes of all canvases allocated, including supersampling and AutoTrim passes
+}
+
+// Stats collects a RenderStat for every render it is passed to through
+// RenderOptions. It is safe for concurrent use, so one Stats can be shared by
+// all sizes of an icon.
+type Stats struct {
+	mu      sync.Mutex
+	renders []RenderStat
+}
+
+// Renders returns the collected stats in the order the renders finished.
+func (s *Stats) Renders() []RenderStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RenderStat(nil), s.renders...)
+}
+
+// add records a finished render.
+func (s *Stats) add(stat RenderStat) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.renders = append(s.renders, stat)
+}
+
+// trackCanvas adds the bytes of a newly allocated canvas to the current
+// render's total. It does nothing when neither a Logger nor Stats is set.
+func (o RenderOptions) trackCanvas(bytes int) {
+	if o.allocated != nil {
+		*o.allocated += bytes
+	}
+}
+
+// estimateCanvasBytes returns about how many bytes of canvases a square
+// render at pxSize with opts allocates, before allocating any of them. It
+// mirrors what trackCanvas adds up during the render and is a float64, so
+// absurd sizes can't overflow.
+func estimateCanvasBytes(pxSize int, opts RenderOptions) float64 {
+	target := 4 * float64(pxSize) * float64(pxSize)
+	drawn := target
+	if opts.Supersample >= 2 && opts.Antialias {
+		scale := float64(opts.Supersample)
+		drawn = target * scale * scale
+		if opts.GammaCorrect {
+			// The linear copies hold 16 bits per channel
+			drawn += 2*drawn + 2*target
+		}
+		drawn += target
+	}
+	if !opts.Antialias {
+		// The artwork is thresholded on its own layer
+		drawn += target
+	}
+	if opts.AutoTrim {
+		// A first pass finds the bounding box of the artwork
+		drawn *= 2
+	}
+	return drawn
+}
+
+// formatBytes formats a byte count with a binary unit, e.g. "4.0 MiB".
+func formatBytes(bytes int) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	value, exp := float64(bytes)/unit, 0
+	for value >= unit && exp < 2 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMG"[exp])
+}
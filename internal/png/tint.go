@@ -0,0 +1,33 @@
+package png
+
+import (
+	"image/color"
+	"reflect"
+
+	"github.com/srwiley/oksvg"
+)
+
+// tint replaces the fill and stroke color of every path of the icon with c.
+// The paths are copied, so the parsed icon shared between renders isn't
+// modified.
+func tint(icon *oksvg.SvgIcon, c color.Color) {
+	paths := make([]oksvg.SvgPath, len(icon.SVGPaths))
+	for i, path := range icon.SVGPaths {
+		if isPainted(path.PathStyle, "fillerColor") {
+			path.SetFillColor(c)
+		}
+		if isPainted(path.PathStyle, "linerColor") {
+			path.SetLineColor(c)
+		}
+		paths[i] = path
+	}
+	icon.SVGPaths = paths
+}
+
+// isPainted reports whether the paint field of a path style holds a color or
+// gradient. oksvg doesn't export whether a fill or stroke is none, its getters
+// report black instead, so the unexported field is inspected directly.
+func isPainted(style oksvg.PathStyle, field string) bool {
+	paint := reflect.ValueOf(style).FieldByName(field)
+	return paint.IsValid() && !paint.IsNil()
+}
@@ -0,0 +1,94 @@
+package png
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// multicolorSvg mixes a solid fill, a gradient, a stroke, an unpainted fill
+// and a semi-transparent shape.
+const multicolorSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">
+<defs><linearGradient id="g" x2="1"><stop offset="0" stop-color="#0c0"/><stop offset="1" stop-color="#00f"/></linearGradient></defs>
+<rect x="4" y="4" width="26" height="26" fill="#fc0"/>
+<rect x="34" y="4" width="26" height="26" fill="url(#g)"/>
+<circle cx="16" cy="48" r="10" fill="none" stroke="#0ff" stroke-width="4"/>
+<circle cx="48" cy="48" r="10" fill="#f0f" fill-opacity="0.5"/>
+</svg>`
+
+// offColor returns the number of visible pixels of img whose premultiplied
+// color differs from the fully saturated want at the pixel's alpha.
+func offColor(img *image.RGBA, want color.RGBA) int {
+	count := 0
+	for i := 0; i < len(img.Pix); i += 4 {
+		pixel, alpha := img.Pix[i:i+3], uint32(img.Pix[i+3])
+		if alpha == 0 {
+			continue
+		}
+		for c, channel := range []uint8{want.R, want.G, want.B} {
+			if uint32(pixel[c]) != uint32(channel)*alpha/255 {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+func TestTint(t *testing.T) {
+	original := rasterizeTest(t, multicolorSvg, 64, DefaultRenderOptions())
+
+	tests := []struct {
+		name string
+		tint color.RGBA
+	}{
+		{"red", color.RGBA{R: 255, A: 255}},
+		{"blue", color.RGBA{B: 255, A: 255}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := DefaultRenderOptions()
+			opts.Tint = test.tint
+			img := rasterizeTest(t, multicolorSvg, 64, opts)
+
+			if off := offColor(img, test.tint); off != 0 {
+				t.Errorf("%d pixels aren't %v", off, test.tint)
+			}
+
+			// Unpainted fills stay transparent and opacities are kept
+			if got, want := VisiblePixels(img), VisiblePixels(original); got != want {
+				t.Errorf("%d visible pixels, the original has %d", got, want)
+			}
+			if got, want := img.RGBAAt(48, 48).A, original.RGBAAt(48, 48).A; got != want {
+				t.Errorf("semi-transparent shape has alpha %#x, want %#x", got, want)
+			}
+			if alpha := img.RGBAAt(16, 48).A; alpha != 0 {
+				t.Errorf("unpainted fill has alpha %#x, want 0", alpha)
+			}
+		})
+	}
+
+	// Without a Tint the colors of the SVG are kept
+	if off := offColor(original, color.RGBA{R: 255, A: 255}); off == 0 {
+		t.Error("untinted render is pure red")
+	}
+
+	// The parsed icon shared between renders keeps its colors
+	icon, err := ParseSvgReader(bytes.NewReader([]byte(multicolorSvg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultRenderOptions()
+	opts.Tint = color.Black
+	if _, err := RasterizeIcon(icon, 64, opts); err != nil {
+		t.Fatal(err)
+	}
+	again, err := RasterizeIcon(icon, 64, DefaultRenderOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.Pix, original.Pix) {
+		t.Error("tinting modified the parsed icon")
+	}
+}
@@ -0,0 +1,51 @@
+package png
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrNoViewBox is returned by Validate for SVGs without a usable viewBox or
+// width and height, which leave nothing to scale to the icon sizes.
+var ErrNoViewBox = errors.New("SVG has no usable viewBox or width and height")
+
+// validateRenderSize is the size of the test render done by Validate.
+const validateRenderSize = 8
+
+// Validate checks that svg can be converted, without writing any output.
+//
+// The markup is parsed like ParseSvgReader, so non-SVG input and unsupported
+// elements are rejected the same way. The parsed icon must have a viewBox
+// (or width and height) with a positive, finite size, and a tiny test render
+// must complete. This is much cheaper than a full conversion, e.g. to reject
+// uploads early.
+//
+// Parameters:
+//   - svg: SVG markup to check
+//
+// Returns nil if the SVG can be rendered, or an error describing why not:
+// ErrNotSvg, ErrUnsupportedElement, ErrNoViewBox, ErrRenderPanic or another parse
+// or render error.
+func Validate(svg []byte) error {
+	icon, err := ParseSvgReader(bytes.NewReader(svg))
+	if err != nil {
+		return err
+	}
+
+	viewBox := icon.ViewBox
+	for _, value := range []float64{viewBox.X, viewBox.Y, viewBox.W, viewBox.H} {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return ErrNoViewBox
+		}
+	}
+	if viewBox.W <= 0 || viewBox.H <= 0 {
+		return fmt.Errorf("%w: got %gx%g", ErrNoViewBox, viewBox.W, viewBox.H)
+	}
+
+	if _, err := RasterizeIcon(icon, validateRenderSize, DefaultRenderOptions()); err != nil {
+		return fmt.Errorf("test render failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package png
+
+import (
+	"bytes"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/srwiley/oksvg"
+)
+
+// SvgToWebp converts an SVG file to lossless WebP format at the specified pixel size.
+//
+// WebP files are usually smaller than the equivalent PNG, which makes them a
+// good extra format for favicons. The encoder is pure Go and needs no cgo.
+//
+// Parameters:
+//   - svgPath: Path to the source SVG file
+//   - pxSize: Output dimensions in pixels (width and height)
+//
+// Returns the WebP-encoded image data as bytes, or an error if conversion fails.
+func SvgToWebp(svgPath string, pxSize int) ([]byte, error) {
+	svgFile, err := openSvg(svgPath)
+	if err != nil {
+		return nil, err
+	}
+	defer svgFile.Close()
+
+	icon, err := ParseSvgReader(svgFile)
+	if err != nil {
+		return nil, withPath(svgPath, err)
+	}
+	return RenderIconWebp(icon, pxSize)
+}
+
+// RenderIconWebp rasterizes a parsed SVG icon to lossless WebP format at the
+// specified pixel size.
+//
+// Parameters:
+//   - icon: Parsed SVG icon as returned by ParseSvg
+//   - pxSize: Output dimensions in pixels (width and height)
+//
+// Returns the WebP-encoded image data as bytes, or an error if encoding fails.
+func RenderIconWebp(icon *oksvg.SvgIcon, pxSize int) ([]byte, error) {
+	return RenderIconWebpOpts(icon, pxSize, DefaultRenderOptions())
+}
+
+// RenderIconWebpOpts rasterizes a parsed SVG icon to lossless WebP format at
+// the specified pixel size using the given render options.
+//
+// Parameters:
+//   - icon: Parsed SVG icon as returned by ParseSvg
+//   - pxSize: Output dimensions in pixels (width and height)
+//   - opts: Options controlling the rasterization
+//
+// Returns the WebP-encoded image data as bytes, or an error if encoding fails.
+func RenderIconWebpOpts(icon *oksvg.SvgIcon, pxSize int, opts RenderOptions) ([]byte, error) {
+	canvas, err := RasterizeIcon(icon, pxSize, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	if err := nativewebp.Encode(&buffer, canvas, nil); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
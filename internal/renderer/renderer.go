@@ -0,0 +1,66 @@
+// Package renderer renders icons in every supported format from SVG markup
+// that is parsed only once, for long-running callers such as servers that
+// serve the same artwork repeatedly.
+package renderer
+
+import (
+	"bytes"
+	"github.com/julian-bruyers/svg2icon/internal/icns"
+	"github.com/julian-bruyers/svg2icon/internal/ico"
+	"github.com/julian-bruyers/svg2icon/internal/png"
+	"github.com/srwiley/oksvg"
+)
+
+// Renderer holds a parsed SVG and renders it as PNG, ICO or ICNS.
+//
+// A Renderer is safe for concurrent use by multiple goroutines. The parsed
+// icon is never modified after NewRenderer returns: every render positions
+// and draws its own copy of the icon, so the transform oksvg changes while
+// drawing is never shared between calls and no locking is needed. Renders
+// running at the same time therefore don't wait for each other.
+type Renderer struct {
+	icon *oksvg.SvgIcon
+}
+
+// NewRenderer parses the SVG markup once for all later renders.
+//
+// Parameters:
+//   - svg: SVG markup
+//
+// Returns the Renderer, or an error if the markup can't be parsed.
+func NewRenderer(svg []byte) (*Renderer, error) {
+	icon, err := png.ParseSvgReader(bytes.NewReader(svg))
+	if err != nil {
+		return nil, err
+	}
+	return &Renderer{icon: icon}, nil
+}
+
+// PNG renders the icon as a square PNG image.
+//
+// Parameters:
+//   - size: Output dimensions in pixels (width and height)
+//
+// Returns the PNG-encoded image data, or an error if rendering fails.
+func (r *Renderer) PNG(size int) ([]byte, error) {
+	return png.RenderIcon(r.icon, size)
+}
+
+// ICO renders the icon as a Windows ICO file.
+//
+// Parameters:
+//   - sizes: Pixel sizes of the embedded images, each between
+//     ico.MinIconSize and ico.MaxIconSize
+//
+// Returns the complete ICO byte stream, or an error if the sizes are invalid
+// or rendering fails.
+func (r *Renderer) ICO(sizes []int) ([]byte, error) {
+	return ico.EncodeIcoIcon(r.icon, sizes, ico.Options{})
+}
+
+// ICNS renders the icon as a macOS ICNS file with the standard icon types.
+//
+// Returns the complete ICNS byte stream, or an error if rendering fails.
+func (r *Renderer) ICNS() ([]byte, error) {
+	return icns.EncodeIcnsIcon(r.icon, icns.Options{})
+}
@@ -0,0 +1,66 @@
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// testSvg has paths, a gradient and a stroke, which all depend on the
+// transform oksvg sets for every render.
+const testSvg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">
+<defs><linearGradient id="g" x2="1"><stop offset="0" stop-color="#f60"/><stop offset="1" stop-color="#06f"/></linearGradient></defs>
+<rect width="64" height="64" fill="url(#g)"/>
+<circle cx="32" cy="32" r="20" fill="none" stroke="#fff" stroke-width="4"/>
+</svg>`
+
+// TestConcurrentRenders renders every format from many goroutines at once
+// and compares the results with sequential renders. Run it with -race to
+// detect shared state that is modified while drawing.
+func TestConcurrentRenders(t *testing.T) {
+	renderer, err := NewRenderer([]byte(testSvg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		render func() ([]byte, error)
+	}{
+		{"PNG 16", func() ([]byte, error) { return renderer.PNG(16) }},
+		{"PNG 256", func() ([]byte, error) { return renderer.PNG(256) }},
+		{"ICO", func() ([]byte, error) { return renderer.ICO([]int{16, 32, 48}) }},
+		{"ICNS", renderer.ICNS},
+	}
+
+	want := make([][]byte, len(tests))
+	for i, test := range tests {
+		if want[i], err = test.render(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+	}
+
+	const rounds = 2
+	var wg sync.WaitGroup
+	errs := make(chan error, rounds*len(tests))
+	for range rounds {
+		for i, test := range tests {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, err := test.render()
+				if err != nil {
+					errs <- fmt.Errorf("%s: %w", test.name, err)
+				} else if !bytes.Equal(got, want[i]) {
+					errs <- fmt.Errorf("%s: concurrent render differs from the sequential one", test.name)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
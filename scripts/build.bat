@@ -23,6 +23,9 @@ if not exist "main.go" (
 
 echo Building svg2icon for multiple platforms...
 
+REM Version embedded into the binaries, override with set VERSION=v1.2.3
+if not defined VERSION set "VERSION=dev"
+
 REM Create build directory safely
 if not exist "build" (
     mkdir build
@@ -95,7 +98,7 @@ set "OUTPUT_PATH=build\!BINARY_NAME!"
 set GOOS=!GOOS_VAL!
 set GOARCH=!GOARCH_VAL!
 
-go build -ldflags="-s -w" -o "!OUTPUT_PATH!" .
+go build -ldflags="-s -w -X github.com/julian-bruyers/svg2icon/cmd/svg2icon.Version=!VERSION!" -o "!OUTPUT_PATH!" .
 if !errorlevel! neq 0 (
     echo Error: Failed to build !DESC! >&2
     exit /b 1
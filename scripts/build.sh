@@ -18,6 +18,10 @@ fi
 
 echo "Building svg2icon for multiple platforms..."
 
+# Version embedded into the binaries, override with VERSION=v1.2.3
+VERSION="${VERSION:-$(git describe --tags --always 2>/dev/null || echo dev)}"
+LDFLAGS="-s -w -X github.com/julian-bruyers/svg2icon/cmd/svg2icon.Version=$VERSION"
+
 # Get project root and validate
 PROJECT_ROOT="$(pwd)"
 BUILD_DIR="$PROJECT_ROOT/build"
@@ -45,7 +49,7 @@ build_target() {
     local binary_name="svg2icon_${goos}_${goarch}${extension}"
     local output_path="$BUILD_DIR/$binary_name"
     
-    if ! GOOS="$goos" GOARCH="$goarch" go build -ldflags="-s -w" -o "$output_path" .; then
+    if ! GOOS="$goos" GOARCH="$goarch" go build -ldflags="$LDFLAGS" -o "$output_path" .; then
         echo "Error: Failed to build $description" >&2
         return 1
     fi